@@ -2,27 +2,89 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/qawatake/hiden/internal/finder"
+	"github.com/qawatake/hiden/internal/repo"
 )
 
-const defaultDirname = ".hiden"
+const (
+	defaultDirname     = ".hiden"
+	defaultRanking     = string(finder.RankingBlend)
+	defaultSource      = "ghq"
+	defaultRepoDisplay = repo.DisplayShort
+	defaultWorkers     = 16
+	defaultRepoTimeout = Duration(10 * time.Second)
+	defaultScanTimeout = Duration(60 * time.Second)
+)
 
 type Config struct {
 	Dirname string `json:"dirname"`
+	// Ranking decides the order of search results: "blend", "score" or "recency".
+	Ranking string `json:"ranking"`
+	// Source decides how repositories are discovered: "ghq", "roots", "list" or "current".
+	Source string `json:"source"`
+	// Roots are the directories scanned for repositories by the "roots" source.
+	Roots []string `json:"roots"`
+	// Repos are the repositories used by the "list" source.
+	Repos []string `json:"repos"`
+	// Layout is the layout of the date-based directory: a Go time layout
+	// that may contain {date}, {week} and {branch}. Empty means "2006-01-02".
+	Layout string `json:"layout"`
+	// Workers is the maximum number of repositories scanned in parallel. Zero means no limit.
+	Workers int `json:"workers"`
+	// RepoTimeout is the time after which the scan of a repository is abandoned. Zero means no limit.
+	RepoTimeout Duration `json:"repo_timeout"`
+	// ScanTimeout is the time after which the scan of all repositories is abandoned. Zero means no limit.
+	ScanTimeout Duration `json:"scan_timeout"`
+	// Ignore holds gitignore-style patterns of files and directories left out
+	// of every hiden directory.
+	Ignore []string `json:"ignore"`
+	// FollowSymlinks makes the scan descend into symlinked directories inside hiden directories.
+	FollowSymlinks bool `json:"follow_symlinks"`
+	// RepoDisplay decides how repositories are shown: "short" (api),
+	// "owner" (org/api) or "full" (github.com/org/api).
+	RepoDisplay string `json:"repo_display"`
+}
+
+// Duration is a time.Duration written as a string such as "5s" in the config file.
+// Zero means no limit.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s: must be a string such as \"5s\"", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid duration %q: must be a non-negative duration such as \"5s\"", s)
+	}
+	*d = Duration(v)
+	return nil
 }
 
 func Load() (*Config, error) {
 	cfg := &Config{
-		Dirname: defaultDirname,
+		Dirname:     defaultDirname,
+		Ranking:     defaultRanking,
+		Source:      defaultSource,
+		RepoDisplay: defaultRepoDisplay,
+		Workers:     defaultWorkers,
+		RepoTimeout: defaultRepoTimeout,
+		ScanTimeout: defaultScanTimeout,
 	}
 
-	homeDir, err := os.UserHomeDir()
+	dir, err := Dir()
 	if err != nil {
 		return cfg, nil
 	}
 
-	configPath := filepath.Join(homeDir, ".config", "hiden", "config.json")
+	configPath := filepath.Join(dir, "config.json")
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -38,6 +100,74 @@ func Load() (*Config, error) {
 	if cfg.Dirname == "" {
 		cfg.Dirname = defaultDirname
 	}
+	if cfg.Source == "" {
+		cfg.Source = defaultSource
+	}
+	if cfg.Ranking == "" {
+		cfg.Ranking = defaultRanking
+	}
+	if !slices.Contains(finder.Rankings, finder.Ranking(cfg.Ranking)) {
+		return nil, fmt.Errorf("invalid ranking %q: must be one of %v", cfg.Ranking, finder.Rankings)
+	}
+	if cfg.RepoDisplay == "" {
+		cfg.RepoDisplay = defaultRepoDisplay
+	}
+	if !slices.Contains(repo.Displays, cfg.RepoDisplay) {
+		return nil, fmt.Errorf("invalid repo_display %q: must be one of %v", cfg.RepoDisplay, repo.Displays)
+	}
+	if cfg.Workers < 0 {
+		return nil, fmt.Errorf("invalid workers %d: must not be negative", cfg.Workers)
+	}
+	if cfg.RepoTimeout < 0 {
+		return nil, fmt.Errorf("invalid repo_timeout %s: must not be negative", time.Duration(cfg.RepoTimeout))
+	}
+	if cfg.ScanTimeout < 0 {
+		return nil, fmt.Errorf("invalid scan_timeout %s: must not be negative", time.Duration(cfg.ScanTimeout))
+	}
 
 	return cfg, nil
 }
+
+// Dir returns the directory holding the config file.
+func Dir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "hiden"), nil
+}
+
+// TemplatesDir returns the directory holding note templates.
+func TemplatesDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// StateDir returns the directory holding state such as the journal,
+// $XDG_STATE_HOME/hiden or ~/.local/state/hiden.
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "hiden"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "state", "hiden"), nil
+}
+
+// CacheDir returns the directory holding caches such as the file index,
+// $XDG_CACHE_HOME/hiden or ~/.cache/hiden.
+func CacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "hiden"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".cache", "hiden"), nil
+}
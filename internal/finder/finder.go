@@ -1,159 +1,486 @@
 package finder
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
-	"github.com/sourcegraph/conc/pool"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/qawatake/hiden/internal/index"
+	"github.com/qawatake/hiden/internal/repo"
 )
 
 var ErrCancelled = errors.New("cancelled")
 
+// ErrNoTTY is returned when the interactive selector cannot open a terminal.
+var ErrNoTTY = errors.New("no terminal available (use --list or --json for non-interactive output)")
+
 type entry struct {
-	absPath      string
-	relPath      string
-	repoName     string
+	absPath string
+	// realPath is absPath with the symlink of the hiden directory resolved.
+	// Entries with the same realPath are shown once.
+	realPath string
+	relPath  string
+	repoPath string
+	repoID   repo.Identity
+	// repoName is repoID in the configured display form.
+	repoName string
+	// linked reports that the hiden directory of the repository is a symlink.
+	linked bool
+	// sharedWith holds the other repositories whose hiden directory holds the same file.
+	sharedWith   []repoRef
 	modTime      time.Time
 	displayLabel string
+	// line is the 1-based line number of a content match, or 0 for a file.
+	line int
+	// matched holds the rune indexes of displayLabel matched by the query.
+	matched []int
 }
 
-func Run(dirname string) (string, error) {
-	repos, err := ghqRepos()
-	if err != nil {
-		return "", err
-	}
+// key identifies the entry among files and content matches.
+func (e entry) key() string {
+	return fmt.Sprintf("%s:%d", e.absPath, e.line)
+}
 
-	entries, err := collectFiles(repos, dirname)
-	if err != nil {
-		return "", err
+// repoRef is a repository sharing the file of an entry.
+type repoRef struct {
+	id   repo.Identity
+	name string
+}
+
+// repoNames returns the displayed names of the repositories of the entry.
+func (e entry) repoNames() []string {
+	names := []string{e.repoName}
+	for _, ref := range e.sharedWith {
+		names = append(names, ref.name)
 	}
+	return names
+}
+
+// repoLabel returns the repositories shown in the label of the entry.
+func (e entry) repoLabel() string {
+	return strings.Join(e.repoNames(), ", ")
+}
 
-	if len(entries) == 0 {
-		return "", nil
+// inRepo reports whether spec, as in the query term @spec, names one of the
+// repositories of the entry (see repo.Identity.Match).
+func (e entry) inRepo(spec string) bool {
+	if e.repoID.Match(spec) {
+		return true
+	}
+	for _, ref := range e.sharedWith {
+		if ref.id.Match(spec) {
+			return true
+		}
 	}
+	return false
+}
 
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].modTime.After(entries[j].modTime)
-	})
+// fileLabel returns the label of a file entry.
+func fileLabel(e entry) string {
+	return fmt.Sprintf("%s  %s  [%s]", e.modTime.Format("2006-01-02"), e.relPath, e.repoLabel())
+}
+
+// Options configures Run.
+type Options struct {
+	// Query is the initial query of the selector.
+	Query string
+	// Content starts the selector in content search mode.
+	Content bool
+	// Line appends ":<line>" to the selected path when a content match is selected.
+	Line bool
+	// Ranking decides the order of matches. Defaults to RankingBlend.
+	Ranking Ranking
+	// SelectOne selects the only match without starting the selector.
+	SelectOne bool
+	// ExitZero returns without starting the selector when nothing matches.
+	ExitZero bool
+	// NoTouch leaves the modification time of the selected files alone.
+	// By default Run sets it to now, so that recently used files rank first.
+	NoTouch bool
+	// Source lists the repositories to search. Defaults to repo.Ghq.
+	Source repo.Source
+	// RepoDisplay is how repositories are shown in labels: repo.DisplayShort
+	// (the default), repo.DisplayOwner or repo.DisplayFull. Repositories are
+	// identified by their path under the roots of a Source implementing repo.RootLister.
+	RepoDisplay string
+	// Cache is the path of the index cache file. The selector starts with the
+	// cached files while the repositories are scanned. Empty disables the cache.
+	Cache string
+	// Workers is the maximum number of repositories scanned in parallel. Zero means no limit.
+	Workers int
+	// RepoTimeout and ScanTimeout abandon the scan of a repository and of all
+	// of them after the given time. Zero means no limit.
+	RepoTimeout time.Duration
+	ScanTimeout time.Duration
+	// Ignore holds gitignore-style patterns of files and directories left out
+	// of every hiden directory, in addition to its .hidenignore file.
+	Ignore []string
+	// FollowSymlinks descends into symlinked directories inside hiden directories.
+	FollowSymlinks bool
+	// Report, if not nil, receives the repositories that were skipped or slow
+	// once the scan completes. With the selector, it is called after the
+	// selector exits, and not at all if the scan was still running.
+	Report func(index.Report)
+}
 
-	for i := range entries {
-		entries[i].displayLabel = fmt.Sprintf("%s  %s  [%s]",
-			entries[i].modTime.Format("2006-01-02"),
-			entries[i].relPath,
-			entries[i].repoName,
-		)
+// File is a file (or a line of it in content search) found in a hiden directory.
+type File struct {
+	Path    string `json:"path"`
+	RelPath string `json:"rel_path"`
+	Repo    string `json:"repo"`
+	// Repos lists every repository sharing the file, when there are several.
+	Repos   []string  `json:"repos,omitempty"`
+	ModTime time.Time `json:"mtime"`
+	Line    int       `json:"line,omitempty"`
+}
+
+// Run lets the user select files with the interactive selector and returns
+// their paths in the order they were selected.
+func Run(dirname string, opts Options) ([]string, error) {
+	var (
+		model selectorModel
+		scan  func(send func(tea.Msg))
+	)
+	if opts.SelectOne || opts.ExitZero {
+		// Deciding whether to start the selector needs every file
+		entries, err := loadEntries(dirname, opts, newRepoNamer(opts))
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return nil, nil
+		}
+		model = newSelector(entries, nil)
+		model.applyOptions(opts)
+		model.readContents()
+	} else {
+		// Start the selector at once with the cached files and stream the
+		// files of each repository into it as soon as it is scanned
+		names := newRepoNamer(opts)
+		model = newSelector(cachedEntries(dirname, opts, names), nil)
+		model.scanning = true
+		scan = func(send func(tea.Msg)) {
+			_, report, err := scanIndex(dirname, opts, names, send)
+			send(scanDoneMsg{report: report, err: err})
+		}
+		model.applyOptions(opts)
 	}
 
-	selectedEntry, err := runSelector(entries)
-	if err != nil {
-		return "", err
+	var selected []entry
+	switch {
+	case len(model.filteredItems) == 0 && opts.ExitZero:
+		return nil, nil
+	case len(model.filteredItems) == 1 && opts.SelectOne:
+		selected = model.filteredItems
+	default:
+		final, err := runSelector(model, scan)
+		if final.report != nil && opts.Report != nil {
+			opts.Report(*final.report)
+		}
+		if errors.Is(err, errNoEntries) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		selected = final.selected
 	}
-	if selectedEntry == nil {
-		return "", ErrCancelled
+	if len(selected) == 0 {
+		return nil, ErrCancelled
 	}
 
-	selected := *selectedEntry
-
 	now := time.Now()
-	if err := os.Chtimes(selected.absPath, now, now); err != nil {
-		return "", fmt.Errorf("failed to update timestamp: %w", err)
+	touched := make(map[string]bool)
+	var paths []string
+	for _, e := range selected {
+		if !opts.NoTouch && !touched[e.absPath] {
+			if err := os.Chtimes(e.absPath, now, now); err != nil {
+				return nil, fmt.Errorf("failed to update timestamp: %w", err)
+			}
+			touched[e.absPath] = true
+		}
+
+		if opts.Line && e.line > 0 {
+			paths = append(paths, fmt.Sprintf("%s:%d", e.absPath, e.line))
+		} else {
+			paths = append(paths, e.absPath)
+		}
 	}
 
-	return selected.absPath, nil
+	return paths, nil
+}
+
+// cachedEntries returns the files in the index cache, or nil when the cache is disabled.
+func cachedEntries(dirname string, opts Options, names repoNamer) []entry {
+	if opts.Cache == "" {
+		return nil
+	}
+	return indexEntries(index.Load(opts.Cache, dirname), names)
 }
 
-func ghqRepos() ([]string, error) {
-	cmd := exec.Command("ghq", "list", "--full-path")
-	output, err := cmd.Output()
+// List returns the files matching opts.Query in the order the selector shows them,
+// without starting the selector.
+func List(dirname string, opts Options) ([]File, error) {
+	entries, err := loadEntries(dirname, opts, newRepoNamer(opts))
 	if err != nil {
-		return nil, fmt.Errorf("failed to run 'ghq list': %w (is ghq installed?)", err)
+		return nil, err
+	}
+
+	model := newSelector(entries, nil)
+	model.applyOptions(opts)
+	model.readContents()
+
+	files := make([]File, len(model.filteredItems))
+	for i, e := range model.filteredItems {
+		files[i] = File{
+			Path:    e.absPath,
+			RelPath: e.relPath,
+			Repo:    e.repoName,
+			ModTime: e.modTime,
+			Line:    e.line,
+		}
+		if len(e.sharedWith) > 0 {
+			files[i].Repos = e.repoNames()
+		}
 	}
+	return files, nil
+}
+
+// Summary describes a scan of the hiden directories.
+type Summary struct {
+	// Repos is the number of repositories listed by the source.
+	Repos int
+	// HidenDirs is the number of repositories with a hiden directory.
+	HidenDirs int
+	// Files is the number of files found.
+	Files int
+	index.Report
+}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var repos []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			repos = append(repos, line)
+// Check scans the hiden directories like List and summarizes the scan,
+// including the repositories, files and directories that could not be scanned.
+func Check(dirname string, opts Options) (Summary, error) {
+	var sum Summary
+	ix, report, err := scanIndex(dirname, opts, repoNamer{}, func(msg tea.Msg) {
+		if msg, ok := msg.(reposMsg); ok {
+			sum.Repos = msg.total
 		}
+	})
+	if err != nil {
+		return Summary{}, err
 	}
 
-	if len(repos) == 0 {
-		return nil, errors.New("no repositories found via ghq list")
+	sum.Report = report
+	sum.HidenDirs = len(ix.Repos)
+	for _, r := range ix.Repos {
+		sum.Files += len(r.Files)
 	}
+	return sum, nil
+}
 
-	return repos, nil
+// loadEntries collects the files of all hiden directories, newest first.
+func loadEntries(dirname string, opts Options, names repoNamer) ([]entry, error) {
+	ix, report, err := scanIndex(dirname, opts, names, nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Report != nil {
+		opts.Report(report)
+	}
+	return indexEntries(ix, names), nil
 }
 
-func collectFiles(repos []string, dirname string) ([]entry, error) {
-	p := pool.NewWithResults[[]entry]()
+// scanIndex lists the repositories of opts.Source and indexes their hiden
+// directories, updating the cache. send, if not nil, receives a reposMsg once
+// the repositories are listed and a repoMsg, named by names, as soon as each
+// one is scanned.
+func scanIndex(dirname string, opts Options, names repoNamer, send func(tea.Msg)) (*index.Index, index.Report, error) {
+	repos, err := source(opts).Repos()
+	if err != nil {
+		return nil, index.Report{}, err
+	}
+
+	ctx := context.Background()
+	if opts.ScanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.ScanTimeout)
+		defer cancel()
+	}
+
+	ix := index.New(dirname)
+	if opts.Cache != "" {
+		ix = index.Load(opts.Cache, dirname)
+	}
+	ix.Ignore = opts.Ignore
+	ix.FollowSymlinks = opts.FollowSymlinks
 
-	for _, repo := range repos {
-		p.Go(func() []entry {
-			return collectFilesFromRepo(repo, dirname)
-		})
+	var found func(string, *index.Repo)
+	if send != nil {
+		send(reposMsg{total: len(repos)})
+		found = func(path string, r *index.Repo) {
+			var entries []entry
+			if r != nil {
+				entries = repoEntries(r, dirname, names)
+			}
+			send(repoMsg{path: path, entries: entries})
+		}
 	}
+	report := ix.Refresh(ctx, repos, index.Limits{Workers: opts.Workers, RepoTimeout: opts.RepoTimeout}, found)
 
-	results := p.Wait()
+	if opts.Cache != "" {
+		// The cache only speeds up the next run, so failing to save it is not an error
+		_ = ix.Save(opts.Cache)
+	}
+	return ix, report, nil
+}
 
+// indexEntries returns the files in ix, most recently modified first.
+func indexEntries(ix *index.Index, names repoNamer) []entry {
 	var entries []entry
-	for _, result := range results {
-		entries = append(entries, result...)
+	for _, r := range ix.Repos {
+		entries = append(entries, repoEntries(r, ix.Dirname, names)...)
 	}
+	return sortEntries(entries)
+}
 
-	return entries, nil
+// sortEntries sorts entries by modification time, most recent first.
+// Ties are broken by path so that the order does not depend on scan order.
+func sortEntries(entries []entry) []entry {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].modTime.Equal(entries[j].modTime) {
+			return entries[i].modTime.After(entries[j].modTime)
+		}
+		return entries[i].key() < entries[j].key()
+	})
+	return entries
 }
 
+// collectFilesFromRepo scans the hiden directory of the repository at repo
+// and returns its files. The finder scans through the index instead; this is
+// kept for the tests of symlinked hiden directories.
 func collectFilesFromRepo(repo, dirname string) []entry {
-	hidenDir := filepath.Join(repo, dirname)
-	info, err := os.Stat(hidenDir)
-	if err != nil || !info.IsDir() {
+	r, _ := index.Scan(context.Background(), nil, repo, dirname, index.ScanOptions{})
+	if r == nil {
 		return nil
 	}
+	return repoEntries(r, dirname, repoNamer{})
+}
 
-	// Resolve symlink if necessary
-	resolvedHidenDir, err := filepath.EvalSymlinks(hidenDir)
-	if err != nil {
-		// If we can't resolve the symlink, fall back to the original path
-		resolvedHidenDir = hidenDir
+// repoEntries returns the files in the indexed hiden directory of a repository.
+func repoEntries(r *index.Repo, dirname string, names repoNamer) []entry {
+	// Construct the absolute paths using the original hiden directory (symlink)
+	hidenDir := filepath.Join(r.Path, dirname)
+	realDir := r.RealPath
+	if realDir == "" {
+		realDir = hidenDir
 	}
+	repoID := names.identify(r.Path)
+	repoName := repoID.Format(names.display)
 
-	repoName := filepath.Base(repo)
-	var entries []entry
+	entries := make([]entry, 0, len(r.Files))
+	for _, f := range r.Files {
+		e := entry{
+			absPath:  filepath.Join(hidenDir, f.Path),
+			realPath: filepath.Join(realDir, f.Path),
+			relPath:  f.Path,
+			repoPath: r.Path,
+			linked:   r.Linked,
+			repoID:   repoID,
+			repoName: repoName,
+			modTime:  f.ModTime,
+		}
+		e.displayLabel = fileLabel(e)
+		entries = append(entries, e)
+	}
+	return entries
+}
 
-	_ = filepath.WalkDir(resolvedHidenDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
+// source returns the source of the repositories to search.
+func source(opts Options) repo.Source {
+	if opts.Source == nil {
+		return repo.Ghq{}
+	}
+	return opts.Source
+}
+
+// repoNamer names the repositories of entries.
+type repoNamer struct {
+	// roots are the directories under which repositories are identified.
+	roots []string
+	// display is the display form of the names.
+	display string
+}
+
+// newRepoNamer returns the repoNamer of the source of opts. Repositories are
+// named after their base name when the roots cannot be listed.
+func newRepoNamer(opts Options) repoNamer {
+	names := repoNamer{display: opts.RepoDisplay}
+	if lister, ok := source(opts).(repo.RootLister); ok {
+		names.roots, _ = lister.RootDirs()
+	}
+	return names
+}
+
+// identify returns the identity of the repository at path.
+func (n repoNamer) identify(path string) repo.Identity {
+	return repo.Identify(path, n.roots)
+}
+
+// dedupEntries merges the entries of a file shared by several repositories,
+// e.g. worktrees whose hiden directories are symlinks to the same directory,
+// into the first of them, keeping the order of entries otherwise. The entry
+// of the repository that holds the hiden directory itself is preferred, and
+// the other repositories are listed in its label.
+func dedupEntries(entries []entry) []entry {
+	groups := make(map[string][]entry)
+	var order []string
+	for _, e := range entries {
+		k := e.realPath
+		if k == "" {
+			k = e.absPath
 		}
-		if d.IsDir() {
-			return nil
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
 		}
+		groups[k] = append(groups[k], e)
+	}
+	if len(order) == len(entries) {
+		return entries
+	}
 
-		info, err := d.Info()
-		if err != nil {
-			return nil
+	deduped := make([]entry, 0, len(order))
+	for _, k := range order {
+		group := groups[k]
+		if len(group) == 1 {
+			deduped = append(deduped, group[0])
+			continue
 		}
 
-		// Calculate relative path from the resolved directory
-		relPath, _ := filepath.Rel(resolvedHidenDir, path)
-		// Construct the absolute path using the original hiden directory (symlink)
-		absPath := filepath.Join(hidenDir, relPath)
-
-		entries = append(entries, entry{
-			absPath:  absPath,
-			relPath:  relPath,
-			repoName: repoName,
-			modTime:  info.ModTime(),
-		})
-		return nil
-	})
-
-	return entries
+		primary := 0
+		for i, e := range group {
+			if !e.linked {
+				primary = i
+				break
+			}
+		}
+		e := group[primary]
+		var shared []repoRef
+		for i, other := range group {
+			if i != primary && other.repoPath != e.repoPath {
+				shared = append(shared, repoRef{id: other.repoID, name: other.repoName})
+			}
+		}
+		slices.SortFunc(shared, func(a, b repoRef) int { return strings.Compare(a.name, b.name) })
+		e.sharedWith = shared
+		e.displayLabel = fileLabel(e)
+		deduped = append(deduped, e)
+	}
+	return deduped
 }
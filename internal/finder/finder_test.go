@@ -3,7 +3,11 @@ package finder
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/qawatake/hiden/internal/repo"
 )
 
 func TestCollectFilesFromRepo_WithSymlink(t *testing.T) {
@@ -111,3 +115,110 @@ func TestCollectFilesFromRepo_WithoutSymlink(t *testing.T) {
 		t.Errorf("Expected relPath to be 'test.txt', got '%s'", entries[0].relPath)
 	}
 }
+
+func TestList_SharedHidenDir(t *testing.T) {
+	// The repositories are reached through a symlink, as /var is on macOS
+	tmpDir := filepath.Join(t.TempDir(), "src")
+	if err := os.Symlink(t.TempDir(), tmpDir); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	// api holds the hiden directory; its worktrees link to it
+	owner := filepath.Join(tmpDir, "api")
+	if err := os.MkdirAll(filepath.Join(owner, ".hiden"), 0755); err != nil {
+		t.Fatalf("Failed to create hiden dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(owner, ".hiden", "memo.md"), []byte("memo"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	repos := []string{owner}
+	for _, name := range []string{"api-wt2", "api-wt1"} {
+		worktree := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(worktree, 0755); err != nil {
+			t.Fatalf("Failed to create repo dir: %v", err)
+		}
+		if err := os.Symlink(filepath.Join(owner, ".hiden"), filepath.Join(worktree, ".hiden")); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+		// List the worktrees first so that the owner is not merely the first one found
+		repos = append([]string{worktree}, repos...)
+	}
+
+	files, err := List(".hiden", Options{Source: repo.List{Paths: repos}})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected the shared file once, got %+v", files)
+	}
+	f := files[0]
+	if f.Path != filepath.Join(owner, ".hiden", "memo.md") || f.Repo != "api" {
+		t.Errorf("Expected the file of the owning repository, got %+v", f)
+	}
+	if want := []string{"api", "api-wt1", "api-wt2"}; !slices.Equal(f.Repos, want) {
+		t.Errorf("Expected repos %v, got %v", want, f.Repos)
+	}
+}
+
+func TestRun_NoTouch(t *testing.T) {
+	repoDir := t.TempDir()
+	path := filepath.Join(repoDir, ".hiden", "notes.md")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create hiden dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	old := time.Date(2025, 12, 4, 15, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Failed to change mtime: %v", err)
+	}
+
+	opts := Options{Source: repo.List{Paths: []string{repoDir}}, Query: "notes", SelectOne: true, NoTouch: true}
+	paths, err := Run(".hiden", opts)
+	if err != nil || len(paths) != 1 || paths[0] != path {
+		t.Fatalf("Expected %s to be selected, got %v (%v)", path, paths, err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("Expected mtime %v to be kept, got %v (%v)", old, info.ModTime(), err)
+	}
+
+	// By default the selected file is touched
+	opts.NoTouch = false
+	if _, err := Run(".hiden", opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.ModTime().Equal(old) {
+		t.Errorf("Expected mtime to be updated, got %v (%v)", info.ModTime(), err)
+	}
+}
+
+func TestList_Content(t *testing.T) {
+	repoDir := t.TempDir()
+	memo := filepath.Join(repoDir, ".hiden", "2025-12-04", "memo.md")
+	if err := os.MkdirAll(filepath.Dir(memo), 0755); err != nil {
+		t.Fatalf("Failed to create hiden dir: %v", err)
+	}
+	if err := os.WriteFile(memo, []byte("# DB\npsql -h localhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ".hiden", "psql.txt"), []byte("nothing here\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	opts := Options{Source: repo.List{Paths: []string{repoDir}}, Content: true, Query: "psql"}
+	files, err := List(".hiden", opts)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != memo || files[0].Line != 2 {
+		t.Errorf("Expected line 2 of %s, got %+v", memo, files)
+	}
+
+	// Without the selector, as with --select-1
+	opts.SelectOne, opts.NoTouch, opts.Line = true, true, true
+	paths, err := Run(".hiden", opts)
+	if err != nil || len(paths) != 1 || paths[0] != memo+":2" {
+		t.Errorf("Expected %s:2 to be selected, got %v (%v)", memo, paths, err)
+	}
+}
@@ -0,0 +1,184 @@
+package finder
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scores used by fuzzyMatch, modelled after fzf.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// bonusBoundary is given to a character following a separator such as '/' or '-'.
+	bonusBoundary = 8
+	// bonusCamel is given to an upper case character following a lower case one.
+	bonusCamel = 7
+	// bonusConsecutive is given to a character right after the previous match.
+	bonusConsecutive = 4
+	// bonusFirstCharMultiplier multiplies the bonus of the first pattern character.
+	bonusFirstCharMultiplier = 2
+)
+
+// charBonus returns the bonus for matching text[i].
+func charBonus(text []rune, i int) int {
+	if i == 0 {
+		return bonusBoundary
+	}
+	prev, cur := text[i-1], text[i]
+	switch {
+	case strings.ContainsRune("/\\_-. :[]", prev):
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusCamel
+	case !unicode.IsDigit(prev) && unicode.IsDigit(cur):
+		return bonusCamel
+	}
+	return 0
+}
+
+// fuzzyMatch reports whether all characters of pattern appear in text in order,
+// ignoring case. pattern must be lower case.
+// It returns the score of the match and the rune indexes of the matched characters.
+func fuzzyMatch(text, pattern string) (int, []int, bool) {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	pat := []rune(pattern)
+	if len(pat) == 0 {
+		return 0, nil, true
+	}
+	if len(lower) != len(runes) {
+		// Lower casing changed the length; fall back to the original runes
+		lower = runes
+	}
+
+	// Forward scan to find the end of the first occurrence
+	pi := 0
+	end := -1
+	for i, r := range lower {
+		if r == pat[pi] {
+			pi++
+			if pi == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	// Backward scan to find the shortest window ending at end
+	pi = len(pat) - 1
+	start := end
+	for i := end; i >= 0; i-- {
+		if lower[i] == pat[pi] {
+			pi--
+			if pi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	// Score the greedy match inside the window
+	positions := make([]int, 0, len(pat))
+	score := 0
+	pi = 0
+	prev := -1
+	for i := start; i <= end && pi < len(pat); i++ {
+		if lower[i] != pat[pi] {
+			continue
+		}
+
+		bonus := charBonus(runes, i)
+		if prev >= 0 && i == prev+1 {
+			if bonus < bonusConsecutive {
+				bonus = bonusConsecutive
+			}
+		} else if prev >= 0 {
+			score += scoreGapStart + scoreGapExtension*(i-prev-2)
+		}
+		if pi == 0 {
+			bonus *= bonusFirstCharMultiplier
+		}
+
+		score += scoreMatch + bonus
+		positions = append(positions, i)
+		prev = i
+		pi++
+	}
+
+	return score, positions, true
+}
+
+// exactMatch finds pattern in text ignoring case. pattern must be lower case.
+// It returns a score and the rune indexes of the matched characters like fuzzyMatch.
+func exactMatch(text, pattern string) (int, []int, bool) {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	pat := []rune(pattern)
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	for start := 0; start+len(pat) <= len(lower); start++ {
+		if string(lower[start:start+len(pat)]) != pattern {
+			continue
+		}
+
+		score := 0
+		positions := make([]int, 0, len(pat))
+		for i := range pat {
+			bonus := bonusConsecutive
+			if i == 0 {
+				bonus = charBonus(runes, start) * bonusFirstCharMultiplier
+			}
+			score += scoreMatch + bonus
+			positions = append(positions, start+i)
+		}
+		return score, positions, true
+	}
+
+	return 0, nil, false
+}
+
+// matchQuery matches all space separated terms of query against text.
+// A term prefixed with ' is matched as an exact substring instead of fuzzily.
+// It returns the total score and the sorted rune indexes of the matched characters.
+func matchQuery(text string, terms []string) (int, []int, bool) {
+	total := 0
+	seen := make(map[int]bool)
+	for _, term := range terms {
+		var (
+			score     int
+			positions []int
+			ok        bool
+		)
+		if exact, found := strings.CutPrefix(term, "'"); found {
+			if exact == "" {
+				continue
+			}
+			score, positions, ok = exactMatch(text, exact)
+		} else {
+			score, positions, ok = fuzzyMatch(text, term)
+		}
+		if !ok {
+			return 0, nil, false
+		}
+
+		total += score
+		for _, p := range positions {
+			seen[p] = true
+		}
+	}
+
+	positions := make([]int, 0, len(seen))
+	for i := range []rune(text) {
+		if seen[i] {
+			positions = append(positions, i)
+		}
+	}
+	return total, positions, true
+}
@@ -0,0 +1,109 @@
+package finder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		text      string
+		pattern   string
+		ok        bool
+		positions []int
+	}{
+		{"deploy.sh", "dply", true, []int{0, 2, 3, 5}},
+		{"Deploy.sh", "dply", true, []int{0, 2, 3, 5}},
+		{"deploy.sh", "ypld", false, nil},
+		{"memo.md", "", true, nil},
+	}
+
+	for _, tt := range tests {
+		_, positions, ok := fuzzyMatch(tt.text, tt.pattern)
+		if ok != tt.ok {
+			t.Errorf("fuzzyMatch(%q, %q): expected ok=%v, got %v", tt.text, tt.pattern, tt.ok, ok)
+			continue
+		}
+		if !reflect.DeepEqual(positions, tt.positions) {
+			t.Errorf("fuzzyMatch(%q, %q): expected positions %v, got %v", tt.text, tt.pattern, tt.positions, positions)
+		}
+	}
+}
+
+func TestFuzzyMatch_PrefersBoundaryAndConsecutive(t *testing.T) {
+	boundary, _, _ := fuzzyMatch("scripts/deploy.sh", "dep")
+	scattered, _, _ := fuzzyMatch("decompile.py", "dep")
+	if boundary <= scattered {
+		t.Errorf("Expected consecutive boundary match to score higher: %d <= %d", boundary, scattered)
+	}
+
+	// The shortest window is chosen
+	_, positions, _ := fuzzyMatch("d-x-deploy", "dep")
+	if !reflect.DeepEqual(positions, []int{4, 5, 6}) {
+		t.Errorf("Expected positions [4 5 6], got %v", positions)
+	}
+}
+
+func TestMatchQuery_ExactTerm(t *testing.T) {
+	if _, _, ok := matchQuery("deploy.sh", []string{"'dply"}); ok {
+		t.Error("Expected exact term not to match fuzzily")
+	}
+
+	_, positions, ok := matchQuery("deploy.sh [repo]", []string{"'ploy", "rp"})
+	if !ok {
+		t.Fatal("Expected match")
+	}
+	if !reflect.DeepEqual(positions, []int{2, 3, 4, 5, 11, 13}) {
+		t.Errorf("Expected merged positions, got %v", positions)
+	}
+}
+
+func TestFilterItems_Fuzzy(t *testing.T) {
+	items := []entry{
+		{displayLabel: "2025-12-04  memo.md  [repo1]", absPath: "/path/memo.md"},
+		{displayLabel: "2025-12-03  scripts/deploy.sh  [repo2]", absPath: "/path/deploy.sh"},
+	}
+
+	m := newSelector(items, nil)
+	m.input.SetValue("dply")
+	m.filterItems()
+
+	if len(m.filteredItems) != 1 || m.filteredItems[0].absPath != "/path/deploy.sh" {
+		t.Fatalf("Expected only deploy.sh, got %d items", len(m.filteredItems))
+	}
+	if len(m.filteredItems[0].matched) != 4 {
+		t.Errorf("Expected 4 matched positions, got %v", m.filteredItems[0].matched)
+	}
+}
+
+func TestFilterItems_Ranking(t *testing.T) {
+	now := time.Now()
+	items := []entry{
+		{displayLabel: "2025-12-04  dxexpxlxoxy.txt  [repo]", absPath: "/path/recent", modTime: now},
+		{displayLabel: "2025-12-01  deploy.sh  [repo]", absPath: "/path/best", modTime: now.Add(-72 * time.Hour)},
+	}
+
+	m := newSelector(items, nil)
+	m.input.SetValue("deploy")
+
+	m.ranking = RankingRecency
+	m.filterItems()
+	if len(m.filteredItems) != 2 || m.filteredItems[0].absPath != "/path/recent" {
+		t.Errorf("Expected recency order, got %v", labels(m.filteredItems))
+	}
+
+	m.ranking = RankingScore
+	m.filterItems()
+	if len(m.filteredItems) != 2 || m.filteredItems[0].absPath != "/path/best" {
+		t.Errorf("Expected score order, got %v", labels(m.filteredItems))
+	}
+}
+
+func labels(items []entry) []string {
+	var result []string
+	for _, item := range items {
+		result = append(result, item.displayLabel)
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package finder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxContentSize is the largest file searched in content mode.
+const maxContentSize = 1 << 20
+
+// binarySniffLen is the number of leading bytes inspected to detect binary files.
+const binarySniffLen = 8000
+
+// contentBatchSize is the number of files read by one command in content mode.
+const contentBatchSize = 32
+
+// maxContentReads limits the files being read at a time in content mode,
+// so that a query over many files does not start all reads at once.
+const maxContentReads = 256
+
+// maxLineLabelLen limits how much of a matching line is shown in the label.
+const maxLineLabelLen = 200
+
+// isBinary reports whether data looks like the contents of a binary file.
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// readLines returns the lines of the file at path.
+// It returns nil if the file is unreadable, binary or larger than maxContentSize.
+func readLines(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxContentSize {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || isBinary(data) {
+		return nil
+	}
+
+	return strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+}
+
+// contentsMsg delivers the lines of files read in content mode, keyed by absolute path.
+type contentsMsg struct {
+	lines map[string][]string
+}
+
+// loadContentsCmd returns a command reading the lines of the files at paths.
+func loadContentsCmd(paths []string) tea.Cmd {
+	return func() tea.Msg {
+		lines := make(map[string][]string, len(paths))
+		for _, path := range paths {
+			lines[path] = readLines(path)
+		}
+		return contentsMsg{lines: lines}
+	}
+}
+
+// matchContents returns an entry for every line of the files in items that
+// contains all keywords (case-insensitive), with the file lines taken from
+// cache keyed by absolute path. Files missing from cache are skipped, and
+// their paths are returned as unread.
+func matchContents(items []entry, keywords []string, cache map[string][]string) (matched []entry, unread []string) {
+	for _, item := range items {
+		lines, ok := cache[item.absPath]
+		if !ok {
+			unread = append(unread, item.absPath)
+			continue
+		}
+
+		for i, line := range lines {
+			lower := strings.ToLower(line)
+			match := true
+			for _, kw := range keywords {
+				if !strings.Contains(lower, kw) {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+
+			text := strings.TrimSpace(line)
+			if r := []rune(text); len(r) > maxLineLabelLen {
+				text = string(r[:maxLineLabelLen])
+			}
+
+			e := item
+			e.line = i + 1
+			e.displayLabel = fmt.Sprintf("%s:%d: %s  [%s]", item.relPath, e.line, text, item.repoLabel())
+			matched = append(matched, e)
+		}
+	}
+	return matched, unread
+}
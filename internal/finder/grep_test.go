@@ -0,0 +1,119 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// drain runs cmd, feeds its messages to model and runs the commands that
+// Update returns, until no command is left.
+func drain(model tea.Model, cmd tea.Cmd) tea.Model {
+	if cmd == nil {
+		return model
+	}
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		for _, c := range msg {
+			model = drain(model, c)
+		}
+	case nil:
+	default:
+		model, cmd = model.Update(msg)
+		model = drain(model, cmd)
+	}
+	return model
+}
+
+func TestMatchContents(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	memo := filepath.Join(tmpDir, "memo.md")
+	if err := os.WriteFile(memo, []byte("# DB\npsql connection string: postgres://localhost\nother line\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	binary := filepath.Join(tmpDir, "image.png")
+	if err := os.WriteFile(binary, []byte("psql\x00connection"), 0644); err != nil {
+		t.Fatalf("Failed to create binary file: %v", err)
+	}
+
+	items := []entry{
+		{absPath: memo, relPath: "memo.md", repoName: "repo1"},
+		{absPath: binary, relPath: "image.png", repoName: "repo1"},
+	}
+
+	// Files not read yet are left to the caller
+	cache := map[string][]string{memo: readLines(memo)}
+	matched, unread := matchContents(items, []string{"psql", "connection"}, cache)
+	if len(unread) != 1 || unread[0] != binary {
+		t.Errorf("Expected %s to be unread, got %v", binary, unread)
+	}
+
+	if len(matched) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(matched))
+	}
+	if matched[0].line != 2 {
+		t.Errorf("Expected line 2, got %d", matched[0].line)
+	}
+	expectedLabel := "memo.md:2: psql connection string: postgres://localhost  [repo1]"
+	if matched[0].displayLabel != expectedLabel {
+		t.Errorf("Expected label %q, got %q", expectedLabel, matched[0].displayLabel)
+	}
+	if lines := readLines(binary); lines != nil {
+		t.Errorf("Expected no lines for the binary file, got %q", lines)
+	}
+}
+
+func TestFilterItems_ContentMode(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	memo := filepath.Join(tmpDir, "memo.md")
+	if err := os.WriteFile(memo, []byte("first\nPSQL Connection\nthird\nmore psql\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	notes := filepath.Join(tmpDir, "psql.txt")
+	if err := os.WriteFile(notes, []byte("nothing here\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	items := []entry{
+		{displayLabel: "2025-12-04  memo.md  [repo1]", absPath: memo, relPath: "memo.md", repoName: "repo1"},
+		{displayLabel: "2025-12-03  psql.txt  [repo2]", absPath: notes, relPath: "psql.txt", repoName: "repo2"},
+	}
+
+	m := newSelector(items, nil)
+	m.input.SetValue("psql")
+	m.setContentMode(true)
+
+	// The files are read by commands, outside Update
+	if len(m.filteredItems) != 0 || len(m.unread) != 2 {
+		t.Fatalf("Expected no match before the files are read, got %d items and %v unread", len(m.filteredItems), m.unread)
+	}
+	if view := m.View(); !strings.Contains(view, "reading 2 files") {
+		t.Errorf("Expected read progress in the header, got %q", view)
+	}
+	cmd := m.loadContents()
+	if len(m.loading) != 2 {
+		t.Errorf("Expected 2 files being read, got %v", m.loading)
+	}
+	if m.loadContents() != nil {
+		t.Error("Expected no command for the files being read")
+	}
+	m = drain(m, cmd).(selectorModel)
+
+	// Should match lines of memo.md only, not the file name psql.txt
+	if len(m.filteredItems) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(m.filteredItems))
+	}
+	if m.filteredItems[0].line != 2 || m.filteredItems[1].line != 4 {
+		t.Errorf("Expected lines 2 and 4, got %d and %d", m.filteredItems[0].line, m.filteredItems[1].line)
+	}
+
+	m.setContentMode(false)
+	if len(m.filteredItems) != 1 || m.filteredItems[0].absPath != notes {
+		t.Errorf("Expected only %s in name mode, got %d items", notes, len(m.filteredItems))
+	}
+}
@@ -0,0 +1,85 @@
+package finder
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxPreviewBytes is the number of leading bytes of a file shown in the preview.
+const maxPreviewBytes = 64 << 10
+
+// previewContext is the number of lines shown above a content match.
+const previewContext = 3
+
+type preview struct {
+	lines []string
+	// note describes why the contents are partial or missing.
+	note string
+}
+
+type previewMsg struct {
+	path    string
+	preview preview
+}
+
+// loadPreview reads the head of the file at path for the preview pane.
+func loadPreview(path string) preview {
+	f, err := os.Open(path)
+	if err != nil {
+		return preview{note: err.Error()}
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return preview{note: err.Error()}
+	}
+
+	buf := make([]byte, maxPreviewBytes)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return preview{note: err.Error()}
+	}
+	data := buf[:n]
+
+	if isBinary(data) {
+		return preview{note: fmt.Sprintf("binary file (%d bytes)", info.Size())}
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = sanitizeLine(line)
+	}
+
+	p := preview{lines: lines}
+	if info.Size() > int64(n) {
+		// Drop the last line since it may be cut in the middle
+		p.lines = lines[:len(lines)-1]
+		p.note = fmt.Sprintf("truncated: showing first %d of %d bytes", n, info.Size())
+	}
+	return p
+}
+
+// sanitizeLine expands tabs and replaces control characters so that a line
+// cannot corrupt the terminal.
+func sanitizeLine(line string) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return '?'
+		}
+		return r
+	}, line)
+}
+
+func loadPreviewCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		return previewMsg{path: path, preview: loadPreview(path)}
+	}
+}
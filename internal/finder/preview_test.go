@@ -0,0 +1,81 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPreview_Text(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memo.md")
+	if err := os.WriteFile(path, []byte("line1\n\tindented\x1b[31m\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	p := loadPreview(path)
+	if p.note != "" {
+		t.Errorf("Expected no note, got %q", p.note)
+	}
+	if len(p.lines) < 2 {
+		t.Fatalf("Expected at least 2 lines, got %d", len(p.lines))
+	}
+	if p.lines[0] != "line1" {
+		t.Errorf("Expected %q, got %q", "line1", p.lines[0])
+	}
+	if p.lines[1] != "    indented?[31m" {
+		t.Errorf("Expected tabs and control characters to be sanitized, got %q", p.lines[1])
+	}
+}
+
+func TestLoadPreview_Binary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(path, []byte("\x89PNG\x00\x00"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	p := loadPreview(path)
+	if len(p.lines) != 0 {
+		t.Errorf("Expected no lines for binary file, got %d", len(p.lines))
+	}
+	if !strings.HasPrefix(p.note, "binary file") {
+		t.Errorf("Expected binary file note, got %q", p.note)
+	}
+}
+
+func TestLoadPreview_Truncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large.txt")
+	data := strings.Repeat("0123456789\n", maxPreviewBytes/11+100)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	p := loadPreview(path)
+	if !strings.HasPrefix(p.note, "truncated") {
+		t.Errorf("Expected truncated note, got %q", p.note)
+	}
+	for i, line := range p.lines {
+		if line != "0123456789" {
+			t.Fatalf("Line %d: expected complete line, got %q", i, line)
+		}
+	}
+}
+
+func TestSyncPreview_ScrollsToContentMatch(t *testing.T) {
+	items := []entry{
+		{absPath: "/path/memo.md", line: 20},
+	}
+
+	m := newSelector(items, nil)
+	if cmd := m.syncPreview(); cmd == nil {
+		t.Error("Expected a command loading the preview")
+	}
+	if m.previewOffset != 20-1-previewContext {
+		t.Errorf("Expected offset %d, got %d", 20-1-previewContext, m.previewOffset)
+	}
+
+	// The preview is already being loaded
+	if cmd := m.syncPreview(); cmd != nil {
+		t.Error("Expected no command while the preview is pending")
+	}
+}
@@ -0,0 +1,62 @@
+package finder
+
+import "sort"
+
+// Ranking decides the order of matched entries.
+type Ranking string
+
+const (
+	// RankingBlend orders matches by a blend of match score and recency.
+	RankingBlend Ranking = "blend"
+	// RankingScore orders matches by match score, then by recency.
+	RankingScore Ranking = "score"
+	// RankingRecency keeps matches in order of modification time.
+	RankingRecency Ranking = "recency"
+)
+
+// Rankings lists the accepted rankings.
+var Rankings = []Ranking{RankingBlend, RankingScore, RankingRecency}
+
+// blendScoreWeight is the weight of the match score in RankingBlend.
+// The rest is given to recency.
+const blendScoreWeight = 0.5
+
+type rankedEntry struct {
+	entry
+	score int
+	// rank is the position of the entry in the list sorted by modification time.
+	rank int
+}
+
+// rankEntries sorts results according to ranking.
+// total is the number of entries the results were matched from.
+func rankEntries(results []rankedEntry, total int, ranking Ranking) []entry {
+	switch ranking {
+	case RankingScore:
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].score > results[j].score
+		})
+	case RankingRecency:
+		// Results are already in order of modification time
+	default:
+		maxScore := 1
+		for _, r := range results {
+			if r.score > maxScore {
+				maxScore = r.score
+			}
+		}
+		value := func(r rankedEntry) float64 {
+			recency := 1 - float64(r.rank)/float64(total)
+			return blendScoreWeight*float64(r.score)/float64(maxScore) + (1-blendScoreWeight)*recency
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return value(results[i]) > value(results[j])
+		})
+	}
+
+	entries := make([]entry, len(results))
+	for i, r := range results {
+		entries[i] = r.entry
+	}
+	return entries
+}
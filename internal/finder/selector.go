@@ -1,46 +1,246 @@
 package finder
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+	"github.com/qawatake/hiden/internal/index"
 )
 
 type selectorModel struct {
+	// repoItems holds the files of every repository, including the files
+	// shared by several of them, which allItems holds once.
+	repoItems     []entry
 	allItems      []entry
 	filteredItems []entry
 	cursor        int
 	input         textinput.Model
 	width         int
 	height        int
-	selected      *entry
-	cancelled     bool
-	renderer      *lipgloss.Renderer
+	selected      []entry
+	// marked holds the entries marked for multi-selection in the order they were marked.
+	marked    []entry
+	cancelled bool
+	renderer  *lipgloss.Renderer
+	ranking   Ranking
+	// contentMode matches the query against file contents instead of labels.
+	contentMode bool
+	// contents caches file lines read in content mode, keyed by absolute path.
+	contents map[string][]string
+	// unread holds the paths of the files the content query needs that are not read yet.
+	unread []string
+	// loading holds the paths of the files being read in content mode.
+	loading map[string]bool
+	// showPreview displays the head of the file under the cursor next to the list.
+	showPreview bool
+	// previews caches loaded previews keyed by absolute path.
+	previews map[string]preview
+	// pending holds the paths whose previews are being loaded.
+	pending map[string]bool
+	// previewKey identifies the entry whose preview is displayed.
+	previewKey    string
+	previewOffset int
+	// scanning reports that the repositories are being scanned.
+	scanning bool
+	spinner  spinner.Model
+	// total and scanned count the repositories to scan and the scanned ones.
+	total, scanned int
+	// seen holds the paths of the scanned repositories.
+	seen map[string]bool
+	// pendingRepos holds the scanned repositories not yet merged into the items.
+	pendingRepos []repoMsg
+	// scanErr is the error that stopped the scan.
+	scanErr error
+	// report describes the repositories skipped or slow and the unreadable
+	// files in the completed scan.
+	report *index.Report
+	// err is returned by runSelector when the scan fails with nothing to show.
+	err error
 }
 
+// errNoEntries is returned by runSelector when the scan finds no files.
+var errNoEntries = errors.New("no files found")
+
+// mergeInterval is how often scanned repositories are merged into the items.
+const mergeInterval = 50 * time.Millisecond
+
+// reposMsg reports the number of repositories to scan.
+type reposMsg struct {
+	total int
+}
+
+// repoMsg delivers the files of a scanned repository.
+type repoMsg struct {
+	path    string
+	entries []entry
+}
+
+// mergeMsg merges the pending repositories into the items.
+type mergeMsg struct{}
+
+// scanDoneMsg reports the end of the scan.
+type scanDoneMsg struct {
+	report index.Report
+	err    error
+}
+
+const (
+	namePrompt    = "> "
+	contentPrompt = "grep> "
+)
+
 func newSelector(items []entry, renderer *lipgloss.Renderer) selectorModel {
 	ti := textinput.New()
 	ti.Placeholder = "Search..."
-	ti.Prompt = "> "
+	ti.Prompt = namePrompt
 	ti.Focus()
 	ti.CharLimit = 200
 	ti.Width = 50
 
+	allItems := dedupEntries(items)
 	return selectorModel{
-		allItems:      items,
-		filteredItems: items,
+		repoItems:     items,
+		allItems:      allItems,
+		filteredItems: allItems,
 		input:         ti,
 		cursor:        0,
 		renderer:      renderer,
+		ranking:       RankingBlend,
+		contents:      make(map[string][]string),
+		loading:       make(map[string]bool),
+		showPreview:   true,
+		previews:      make(map[string]preview),
+		pending:       make(map[string]bool),
+		spinner:       spinner.New(spinner.WithSpinner(spinner.MiniDot)),
+		seen:          make(map[string]bool),
+	}
+}
+
+// setContentMode switches between label and content search and refilters the items.
+func (m *selectorModel) setContentMode(on bool) {
+	m.contentMode = on
+	if on {
+		m.input.Prompt = contentPrompt
+	} else {
+		m.input.Prompt = namePrompt
+	}
+	m.filterItems()
+	m.cursor = 0
+}
+
+// syncPreview resets the preview scroll position when the entry under the
+// cursor changes and returns a command loading its preview if needed.
+func (m *selectorModel) syncPreview() tea.Cmd {
+	if !m.showPreview || len(m.filteredItems) == 0 {
+		return nil
+	}
+
+	item := m.filteredItems[m.cursor]
+	key := item.key()
+	if key != m.previewKey {
+		m.previewKey = key
+		m.previewOffset = 0
+		// Show a few lines above a content match
+		if item.line > previewContext {
+			m.previewOffset = item.line - 1 - previewContext
+		}
+	}
+
+	if _, ok := m.previews[item.absPath]; ok || m.pending[item.absPath] {
+		return nil
+	}
+	m.pending[item.absPath] = true
+	return loadPreviewCmd(item.absPath)
+}
+
+// loadContents returns a command reading the unread files the content query
+// needs, keeping at most maxContentReads files being read.
+func (m *selectorModel) loadContents() tea.Cmd {
+	var paths []string
+	for _, path := range m.unread {
+		if !m.loading[path] {
+			paths = append(paths, path)
+		}
+	}
+
+	var cmds []tea.Cmd
+	for len(paths) > 0 && len(m.loading) < maxContentReads {
+		batch := paths[:min(len(paths), contentBatchSize)]
+		paths = paths[len(batch):]
+		for _, path := range batch {
+			m.loading[path] = true
+		}
+		cmds = append(cmds, loadContentsCmd(batch))
+	}
+	return tea.Batch(cmds...)
+}
+
+// readContents reads the unread files the content query needs and filters
+// the items again, for callers that do not run the selector.
+func (m *selectorModel) readContents() {
+	if len(m.unread) == 0 {
+		return
+	}
+	for _, path := range m.unread {
+		m.contents[path] = readLines(path)
+	}
+	m.refilter()
+}
+
+// isMarked reports whether e is marked for multi-selection.
+func (m selectorModel) isMarked(e entry) bool {
+	for _, marked := range m.marked {
+		if marked.key() == e.key() {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleMark marks or unmarks the entry under the cursor.
+func (m *selectorModel) toggleMark() {
+	if len(m.filteredItems) == 0 {
+		return
+	}
+
+	item := m.filteredItems[m.cursor]
+	for i, marked := range m.marked {
+		if marked.key() == item.key() {
+			m.marked = append(m.marked[:i:i], m.marked[i+1:]...)
+			return
+		}
+	}
+	m.marked = append(m.marked, item)
+}
+
+// scrollPreview moves the preview scroll position by delta lines.
+func (m *selectorModel) scrollPreview(delta int) {
+	m.previewOffset += delta
+	if len(m.filteredItems) > 0 {
+		p := m.previews[m.filteredItems[m.cursor].absPath]
+		if m.previewOffset > len(p.lines)-1 {
+			m.previewOffset = len(p.lines) - 1
+		}
+	}
+	if m.previewOffset < 0 {
+		m.previewOffset = 0
 	}
 }
 
 func (m selectorModel) Init() tea.Cmd {
-	return textinput.Blink
+	cmds := []tea.Cmd{textinput.Blink, m.syncPreview(), m.loadContents()}
+	if m.scanning {
+		cmds = append(cmds, m.spinner.Tick)
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -54,11 +254,25 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 
 		case "enter":
-			if len(m.filteredItems) > 0 {
-				m.selected = &m.filteredItems[m.cursor]
+			if len(m.marked) > 0 {
+				m.selected = m.marked
+			} else if len(m.filteredItems) > 0 {
+				m.selected = []entry{m.filteredItems[m.cursor]}
 			}
 			return m, tea.Quit
 
+		case "tab":
+			m.toggleMark()
+			if m.cursor < len(m.filteredItems)-1 {
+				m.cursor++
+			}
+
+		case "shift+tab":
+			m.toggleMark()
+			if m.cursor > 0 {
+				m.cursor--
+			}
+
 		case "up", "ctrl+p":
 			if m.cursor > 0 {
 				m.cursor--
@@ -69,6 +283,24 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor++
 			}
 
+		case "ctrl+g":
+			m.setContentMode(!m.contentMode)
+
+		case "ctrl+t":
+			m.showPreview = !m.showPreview
+
+		case "shift+up":
+			m.scrollPreview(-1)
+
+		case "shift+down":
+			m.scrollPreview(1)
+
+		case "pgup":
+			m.scrollPreview(-m.listHeight() / 2)
+
+		case "pgdown":
+			m.scrollPreview(m.listHeight() / 2)
+
 		default:
 			m.input, cmd = m.input.Update(msg)
 			m.filterItems()
@@ -81,12 +313,135 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+
+	case previewMsg:
+		m.previews[msg.path] = msg.preview
+		delete(m.pending, msg.path)
+
+	case contentsMsg:
+		for path, lines := range msg.lines {
+			m.contents[path] = lines
+			delete(m.loading, path)
+		}
+		m.refilter()
+
+	case spinner.TickMsg:
+		// Stop ticking once the scan is done
+		if m.scanning {
+			m.spinner, cmd = m.spinner.Update(msg)
+		}
+
+	case reposMsg:
+		m.total = msg.total
+
+	case repoMsg:
+		m.scanned++
+		m.seen[msg.path] = true
+		m.pendingRepos = append(m.pendingRepos, msg)
+		// Merge repositories in batches, since every merge refilters all items
+		if len(m.pendingRepos) == 1 {
+			cmd = tea.Tick(mergeInterval, func(time.Time) tea.Msg { return mergeMsg{} })
+		}
+
+	case mergeMsg:
+		m.mergeRepos()
+
+	case scanDoneMsg:
+		m.scanning = false
+		m.mergeRepos()
+		if msg.err != nil {
+			m.scanErr = msg.err
+			if len(m.allItems) == 0 {
+				m.err = msg.err
+				return m, tea.Quit
+			}
+			break
+		}
+
+		m.report = &msg.report
+
+		// Drop the cached files of repositories no longer listed
+		var items []entry
+		for _, item := range m.repoItems {
+			if m.seen[item.repoPath] {
+				items = append(items, item)
+			}
+		}
+		m.setItems(items)
+		if len(m.allItems) == 0 {
+			m.err = errNoEntries
+			return m, tea.Quit
+		}
+	}
+
+	cmds := tea.Batch(cmd, m.syncPreview(), m.loadContents())
+	return m, cmds
+}
+
+// mergeRepos replaces the items of the pending repositories with their scanned files.
+func (m *selectorModel) mergeRepos() {
+	if len(m.pendingRepos) == 0 {
+		return
+	}
+
+	merged := make(map[string]bool, len(m.pendingRepos))
+	var items []entry
+	for _, msg := range m.pendingRepos {
+		merged[msg.path] = true
+		items = append(items, msg.entries...)
+	}
+	for _, item := range m.repoItems {
+		if !merged[item.repoPath] {
+			items = append(items, item)
+		}
+	}
+	m.pendingRepos = nil
+
+	m.setItems(sortEntries(items))
+}
+
+// setItems replaces the files of the repositories, keeping the cursor on the
+// same item and the marks of the items that still exist.
+func (m *selectorModel) setItems(items []entry) {
+	deduped := dedupEntries(items)
+	keys := make(map[string]bool, len(deduped))
+	for _, item := range deduped {
+		keys[item.key()] = true
+	}
+	var marked []entry
+	for _, e := range m.marked {
+		if keys[e.key()] {
+			marked = append(marked, e)
+		}
+	}
+	m.marked = marked
+
+	m.repoItems = items
+	m.allItems = deduped
+	m.refilter()
+}
+
+// refilter filters the items again, keeping the cursor on the same item if
+// it is still listed.
+func (m *selectorModel) refilter() {
+	var cursorKey string
+	if m.cursor < len(m.filteredItems) {
+		cursorKey = m.filteredItems[m.cursor].key()
 	}
 
-	return m, cmd
+	m.filterItems()
+
+	m.cursor = 0
+	for i, item := range m.filteredItems {
+		if item.key() == cursorKey {
+			m.cursor = i
+			break
+		}
+	}
 }
 
 func (m *selectorModel) filterItems() {
+	m.unread = nil
 	query := strings.TrimSpace(m.input.Value())
 	if query == "" {
 		m.filteredItems = m.allItems
@@ -94,28 +449,88 @@ func (m *selectorModel) filterItems() {
 	}
 
 	// Split by space for AND search
-	keywords := strings.Fields(strings.ToLower(query))
+	keywords, repos := splitRepoTerms(strings.Fields(strings.ToLower(query)))
+	items := m.allItems
+	if len(repos) > 0 {
+		items = filterRepos(items, repos)
+	}
 	if len(keywords) == 0 {
-		m.filteredItems = m.allItems
+		m.filteredItems = items
 		return
 	}
 
-	var filtered []entry
-	for _, item := range m.allItems {
-		label := strings.ToLower(item.displayLabel)
-		match := true
-		for _, kw := range keywords {
-			if !strings.Contains(label, kw) {
-				match = false
-				break
-			}
+	if m.contentMode {
+		m.filteredItems, m.unread = matchContents(items, keywords, m.contents)
+		return
+	}
+
+	var results []rankedEntry
+	for i, item := range items {
+		score, positions, ok := matchQuery(item.displayLabel, keywords)
+		if !ok {
+			continue
+		}
+		item.matched = positions
+		results = append(results, rankedEntry{entry: item, score: score, rank: i})
+	}
+
+	m.filteredItems = rankEntries(results, len(items), m.ranking)
+}
+
+// splitRepoTerms separates the terms of a query from its repository
+// qualifiers, the terms @spec, and returns the specs of the latter.
+func splitRepoTerms(terms []string) (keywords, repos []string) {
+	for _, term := range terms {
+		if spec, ok := strings.CutPrefix(term, "@"); ok && spec != "" {
+			repos = append(repos, spec)
+		} else {
+			keywords = append(keywords, term)
 		}
-		if match {
+	}
+	return keywords, repos
+}
+
+// filterRepos returns the items in the repositories named by all specs.
+func filterRepos(items []entry, specs []string) []entry {
+	var filtered []entry
+	for _, item := range items {
+		if inAllRepos(item, specs) {
 			filtered = append(filtered, item)
 		}
 	}
+	return filtered
+}
+
+// inAllRepos reports whether every spec names a repository of item.
+func inAllRepos(item entry, specs []string) bool {
+	for _, spec := range specs {
+		if !item.inRepo(spec) {
+			return false
+		}
+	}
+	return true
+}
+
+// minPreviewWidth is the terminal width below which the preview pane is hidden.
+const minPreviewWidth = 80
+
+// listHeight returns the number of list rows that fit in the window.
+func (m selectorModel) listHeight() int {
+	visibleHeight := m.height - 5 // Reserve space for input and count
+	if visibleHeight < 1 {
+		visibleHeight = 10
+	}
+	return visibleHeight
+}
 
-	m.filteredItems = filtered
+// paneWidths returns the widths of the list and the preview pane.
+// The preview width is 0 when the preview is hidden.
+func (m selectorModel) paneWidths() (list, preview int) {
+	if !m.showPreview || m.width < minPreviewWidth {
+		return m.width, 0
+	}
+	list = m.width / 2
+	return list, m.width - list - 3
 }
 
 func (m selectorModel) View() string {
@@ -127,13 +542,29 @@ func (m selectorModel) View() string {
 	// Show count
 	countStyle := m.renderer.NewStyle().
 		Foreground(lipgloss.Color("241"))
-	b.WriteString("  " + countStyle.Render(fmt.Sprintf("%d/%d", len(m.filteredItems), len(m.allItems))) + "\n")
+	count := fmt.Sprintf("%d/%d", len(m.filteredItems), len(m.allItems))
+	if len(m.marked) > 0 {
+		count += fmt.Sprintf(" (%d selected)", len(m.marked))
+	}
+	switch {
+	case m.scanning && m.total == 0:
+		count += " " + m.spinner.View() + " listing repositories"
+	case m.scanning:
+		count += " " + m.spinner.View() + fmt.Sprintf(" scanning %d/%d", m.scanned, m.total)
+	case len(m.unread) > 0:
+		count += fmt.Sprintf(" reading %d files", len(m.unread))
+	case m.scanErr != nil:
+		count += fmt.Sprintf(" (scan failed: %v)", m.scanErr)
+	case m.report != nil && m.report.Warnings() == 1:
+		count += " (1 warning)"
+	case m.report != nil && m.report.Warnings() > 1:
+		count += fmt.Sprintf(" (%d warnings)", m.report.Warnings())
+	}
+	b.WriteString("  " + countStyle.Render(count) + "\n")
 
 	// List items
-	visibleHeight := m.height - 5 // Reserve space for input and count
-	if visibleHeight < 1 {
-		visibleHeight = 10
-	}
+	visibleHeight := m.listHeight()
+	listWidth, previewWidth := m.paneWidths()
 
 	start := m.cursor - visibleHeight/2
 	if start < 0 {
@@ -154,62 +585,178 @@ func (m selectorModel) View() string {
 
 	normalStyle := m.renderer.NewStyle()
 
+	var rows []string
 	for i := start; i < end; i++ {
-		cursor := "  "
+		item := m.filteredItems[i]
+
+		cursor := " "
+		style := normalStyle
 		if i == m.cursor {
-			cursor = "> "
+			cursor = ">"
+			style = selectedStyle
 		}
+		mark := "  "
+		if m.isMarked(item) {
+			mark = "* "
+		}
+		matchStyle := style.Copy().
+			Foreground(lipgloss.Color("205")).
+			Bold(true)
 
-		line := cursor + m.filteredItems[i].displayLabel
-
-		if i == m.cursor {
-			b.WriteString(selectedStyle.Render(line) + "\n")
-		} else {
-			b.WriteString(normalStyle.Render(line) + "\n")
+		line := style.Render(cursor+mark) + highlight(item.displayLabel, item.matched, style, matchStyle)
+		if previewWidth > 0 {
+			line = truncate.String(line, uint(listWidth))
 		}
+		rows = append(rows, line)
 	}
 
 	if len(m.filteredItems) == 0 {
 		noResultStyle := m.renderer.NewStyle().
 			Foreground(lipgloss.Color("241")).
 			Italic(true)
-		b.WriteString("  " + noResultStyle.Render("No matches found\n"))
+		rows = append(rows, "  "+noResultStyle.Render("No matches found"))
+	}
+
+	if previewWidth == 0 {
+		for _, row := range rows {
+			b.WriteString(row + "\n")
+		}
+		return b.String()
+	}
+
+	// Render the list and the preview side by side
+	previewRows := m.previewRows(visibleHeight, previewWidth)
+	for i := 0; i < visibleHeight; i++ {
+		left, right := "", ""
+		if i < len(rows) {
+			left = rows[i]
+		}
+		if i < len(previewRows) {
+			right = previewRows[i]
+		}
+		padding := listWidth - lipgloss.Width(left)
+		if padding < 0 {
+			padding = 0
+		}
+		b.WriteString(left + strings.Repeat(" ", padding) + countStyle.Render(" │ ") + right + "\n")
 	}
 
 	return b.String()
 }
 
-func runSelector(items []entry) (*entry, error) {
+// highlight renders label with the runes at positions in matchStyle and the rest in style.
+func highlight(label string, positions []int, style, matchStyle lipgloss.Style) string {
+	if len(positions) == 0 {
+		return style.Render(label)
+	}
+
+	var b strings.Builder
+	var run []rune
+	runMatched := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if runMatched {
+			b.WriteString(matchStyle.Render(string(run)))
+		} else {
+			b.WriteString(style.Render(string(run)))
+		}
+		run = run[:0]
+	}
+
+	next := 0
+	for i, r := range []rune(label) {
+		matched := next < len(positions) && positions[next] == i
+		if matched {
+			next++
+		}
+		if matched != runMatched {
+			flush()
+			runMatched = matched
+		}
+		run = append(run, r)
+	}
+	flush()
+
+	return b.String()
+}
+
+// previewRows renders the preview of the entry under the cursor.
+func (m selectorModel) previewRows(height, width int) []string {
+	if len(m.filteredItems) == 0 {
+		return nil
+	}
+
+	dimStyle := m.renderer.NewStyle().
+		Foreground(lipgloss.Color("241"))
+	matchStyle := m.renderer.NewStyle().
+		Reverse(true)
+
+	item := m.filteredItems[m.cursor]
+	p, ok := m.previews[item.absPath]
+	if !ok {
+		return []string{dimStyle.Render("Loading...")}
+	}
+
+	rows := []string{dimStyle.Render(truncate.String(item.relPath, uint(width)))}
+	if p.note != "" {
+		rows = append(rows, dimStyle.Render(truncate.String(p.note, uint(width))))
+	}
+
+	for i := m.previewOffset; i < len(p.lines) && len(rows) < height; i++ {
+		line := truncate.String(p.lines[i], uint(width))
+		if i+1 == item.line {
+			line = matchStyle.Render(line)
+		}
+		rows = append(rows, line)
+	}
+
+	return rows
+}
+
+// applyOptions sets the ranking, the initial query and the search mode from opts.
+func (m *selectorModel) applyOptions(opts Options) {
+	if opts.Ranking != "" {
+		m.ranking = opts.Ranking
+	}
+	m.input.SetValue(opts.Query)
+	m.setContentMode(opts.Content)
+}
+
+// runSelector runs the selector on the terminal and returns its final state.
+// refresh, if not nil, is run in the background with a function that sends
+// messages to the selector.
+func runSelector(model selectorModel, refresh func(send func(tea.Msg))) (selectorModel, error) {
 	// Open /dev/tty directly to enable interactive UI even in subshells
 	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
 	if err != nil {
-		// Fallback: if /dev/tty is not available, return the first item
-		if len(items) > 0 {
-			return &items[0], nil
-		}
-		return nil, fmt.Errorf("failed to open /dev/tty and no items available: %w", err)
+		return model, fmt.Errorf("%w: %v", ErrNoTTY, err)
 	}
 	defer tty.Close()
 
 	// Create a lipgloss renderer for the tty
-	renderer := lipgloss.NewRenderer(tty)
+	model.renderer = lipgloss.NewRenderer(tty)
 
 	p := tea.NewProgram(
-		newSelector(items, renderer),
+		model,
 		tea.WithInput(tty),
 		tea.WithOutput(tty),
 		tea.WithAltScreen(),
 	)
 
-	model, err := p.Run()
+	if refresh != nil {
+		go refresh(p.Send)
+	}
+
+	final, err := p.Run()
 	if err != nil {
-		return nil, fmt.Errorf("error running selector: %w", err)
+		return model, fmt.Errorf("error running selector: %w", err)
 	}
 
-	m := model.(selectorModel)
+	m := final.(selectorModel)
 	if m.cancelled {
-		return nil, ErrCancelled
+		return m, ErrCancelled
 	}
-
-	return m.selected, nil
+	return m, m.err
 }
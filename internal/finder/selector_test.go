@@ -1,8 +1,15 @@
 package finder
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/qawatake/hiden/internal/index"
+	"github.com/qawatake/hiden/internal/repo"
 )
 
 func TestFilterItems_EmptyQuery(t *testing.T) {
@@ -127,3 +134,171 @@ func TestFilterItems_PreservesOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestFilterItems_RepoQualifier(t *testing.T) {
+	org1 := repo.Identity{Host: "github.com", Owner: "org1", Name: "api"}
+	org2 := repo.Identity{Host: "github.com", Owner: "org2", Name: "api"}
+	items := []entry{
+		{displayLabel: "2025-12-04  memo.md  [api]", absPath: "/org1/api/.hiden/memo.md", repoID: org1},
+		{displayLabel: "2025-12-03  memo.md  [api]", absPath: "/org2/api/.hiden/memo.md", repoID: org2},
+		{displayLabel: "2025-12-02  notes.md  [web]", absPath: "/org2/web/.hiden/notes.md", repoID: repo.Identity{Owner: "org2", Name: "web"}},
+		// A file shared by org1/web and org2/api
+		{displayLabel: "2025-12-01  shared.md  [web, api]", absPath: "/org1/web/.hiden/shared.md",
+			repoID: repo.Identity{Owner: "org1", Name: "web"}, sharedWith: []repoRef{{id: org2, name: "api"}}},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"@api", []string{"/org1/api/.hiden/memo.md", "/org2/api/.hiden/memo.md", "/org1/web/.hiden/shared.md"}},
+		{"@org2/api", []string{"/org2/api/.hiden/memo.md", "/org1/web/.hiden/shared.md"}},
+		{"@GitHub.com/org1/api memo", []string{"/org1/api/.hiden/memo.md"}},
+		{"@org2/api @web", []string{"/org1/web/.hiden/shared.md"}},
+		{"@org3/api", nil},
+		// A lone @ is a search term
+		{"@", nil},
+	}
+
+	for _, tt := range tests {
+		m := newSelector(items, nil)
+		m.ranking = RankingRecency
+		m.input.SetValue(tt.query)
+		m.filterItems()
+
+		var got []string
+		for _, item := range m.filteredItems {
+			got = append(got, item.absPath)
+		}
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("Query %q: expected %v, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestApplyOptions(t *testing.T) {
+	items := []entry{
+		{displayLabel: "2025-12-04  memo.md  [repo1]", absPath: "/path/memo.md"},
+		{displayLabel: "2025-12-03  deploy.sh  [repo2]", absPath: "/path/deploy.sh"},
+	}
+
+	m := newSelector(items, nil)
+	m.applyOptions(Options{Query: "dply", Ranking: RankingScore})
+
+	if m.ranking != RankingScore {
+		t.Errorf("Expected ranking %q, got %q", RankingScore, m.ranking)
+	}
+	if len(m.filteredItems) != 1 || m.filteredItems[0].absPath != "/path/deploy.sh" {
+		t.Errorf("Expected the query to be applied, got %d items", len(m.filteredItems))
+	}
+}
+
+func TestUpdate_MultiSelect(t *testing.T) {
+	items := []entry{
+		{displayLabel: "2025-12-04  a.md  [repo]", absPath: "/path/a.md"},
+		{displayLabel: "2025-12-03  b.md  [repo]", absPath: "/path/b.md"},
+		{displayLabel: "2025-12-02  c.md  [repo]", absPath: "/path/c.md"},
+	}
+
+	var model tea.Model = newSelector(items, nil)
+	press := func(key tea.KeyType) {
+		model, _ = model.Update(tea.KeyMsg{Type: key})
+	}
+
+	// Mark c.md and a.md, then unmark a.md
+	press(tea.KeyDown)
+	press(tea.KeyDown)
+	press(tea.KeyTab)
+	press(tea.KeyUp)
+	press(tea.KeyUp)
+	press(tea.KeyTab)
+	press(tea.KeyUp)
+	press(tea.KeyShiftTab)
+
+	m := model.(selectorModel)
+	if len(m.marked) != 1 || m.marked[0].absPath != "/path/c.md" {
+		t.Fatalf("Expected only c.md to be marked, got %v", labels(m.marked))
+	}
+
+	// Mark b.md
+	press(tea.KeyDown)
+	press(tea.KeyTab)
+	press(tea.KeyEnter)
+
+	m = model.(selectorModel)
+	if len(m.selected) != 2 {
+		t.Fatalf("Expected 2 selected items, got %d", len(m.selected))
+	}
+	if m.selected[0].absPath != "/path/c.md" || m.selected[1].absPath != "/path/b.md" {
+		t.Errorf("Expected selection in marking order, got %v", labels(m.selected))
+	}
+}
+
+func TestUpdate_Scan(t *testing.T) {
+	// Cached files of three repositories
+	items := []entry{
+		{displayLabel: "2025-12-04  a.md  [repo1]", absPath: "/repo1/.hiden/a.md", repoPath: "/repo1", modTime: time.Date(2025, 12, 4, 0, 0, 0, 0, time.UTC)},
+		{displayLabel: "2025-12-03  b.md  [repo2]", absPath: "/repo2/.hiden/b.md", repoPath: "/repo2", modTime: time.Date(2025, 12, 3, 0, 0, 0, 0, time.UTC)},
+		{displayLabel: "2025-12-02  c.md  [repo3]", absPath: "/repo3/.hiden/c.md", repoPath: "/repo3", modTime: time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	m := newSelector(items, nil)
+	m.scanning = true
+	m.marked = []entry{items[0]}
+	m.cursor = 1
+
+	var model tea.Model = m
+	send := func(msg tea.Msg) {
+		model, _ = model.Update(msg)
+	}
+
+	// repo1 has a new file and lost a.md; repo3 is no longer listed
+	send(reposMsg{total: 2})
+	send(repoMsg{path: "/repo1", entries: []entry{
+		{displayLabel: "2025-12-05  d.md  [repo1]", absPath: "/repo1/.hiden/d.md", repoPath: "/repo1", modTime: time.Date(2025, 12, 5, 0, 0, 0, 0, time.UTC)},
+	}})
+
+	m = model.(selectorModel)
+	if m.scanned != 1 || len(m.allItems) != 3 {
+		t.Errorf("Expected the repository to be merged later, got %d scanned and %v", m.scanned, labels(m.allItems))
+	}
+	if view := m.View(); !strings.Contains(view, "scanning 1/2") {
+		t.Errorf("Expected scan progress in the header, got %q", view)
+	}
+
+	send(mergeMsg{})
+	send(repoMsg{path: "/repo2", entries: []entry{items[1]}})
+	send(scanDoneMsg{report: index.Report{Errors: []index.ScanError{
+		{Repo: "/repo2", Path: "/repo2/.hiden/private", Kind: index.KindPermission},
+		{Repo: "/repo2", Path: "/repo2/.hiden/link.md", Kind: index.KindBrokenSymlink},
+	}}})
+
+	m = model.(selectorModel)
+	if m.scanning {
+		t.Error("Expected scanning to be done")
+	}
+	if got := labels(m.allItems); len(got) != 2 || got[0] != "2025-12-05  d.md  [repo1]" || got[1] != "2025-12-03  b.md  [repo2]" {
+		t.Errorf("Expected the scanned files sorted by mtime, got %v", got)
+	}
+	if m.filteredItems[m.cursor].absPath != "/repo2/.hiden/b.md" {
+		t.Errorf("Expected the cursor to stay on b.md, got %s", m.filteredItems[m.cursor].absPath)
+	}
+	if len(m.marked) != 0 {
+		t.Errorf("Expected the mark of the removed a.md to be dropped, got %v", labels(m.marked))
+	}
+	if view := m.View(); !strings.Contains(view, "(2 warnings)") {
+		t.Errorf("Expected the warning count in the header, got %q", view)
+	}
+}
+
+func TestUpdate_ScanFindsNothing(t *testing.T) {
+	var model tea.Model = newSelector(nil, nil)
+	model, cmd := model.Update(scanDoneMsg{})
+
+	if err := model.(selectorModel).err; !errors.Is(err, errNoEntries) {
+		t.Errorf("Expected errNoEntries, got %v", err)
+	}
+	if cmd == nil {
+		t.Error("Expected the selector to quit")
+	}
+}
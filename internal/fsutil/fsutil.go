@@ -0,0 +1,147 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// rename is os.Rename, replaceable in tests to simulate cross-device moves.
+var rename = os.Rename
+
+// Move renames src to dst. When they are on different file systems, it copies
+// src to dst with Copy and then removes src.
+func Move(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := Copy(src, dst); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return fmt.Errorf("copied to %s but failed to remove %s: %w", dst, src, err)
+	}
+	return nil
+}
+
+// Copy copies the file, directory or symlink src to dst recursively,
+// preserving permissions and modification times.
+// The copy is made under a temporary name next to dst and renamed into place,
+// so a failure part-way leaves dst untouched.
+func Copy(src, dst string) error {
+	tmp, err := tempName(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := copyTree(src, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	return nil
+}
+
+// Symlink creates a symlink at dst pointing to target, replacing an existing
+// file at dst like os.Rename does.
+func Symlink(target, dst string) error {
+	tmp, err := tempName(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// tempName returns an unused path in the directory of path.
+func tempName(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	// Only the name is needed; copyTree creates the file or directory itself
+	if err := os.Remove(f.Name()); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func copyTree(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+
+	case info.IsDir():
+		if err := os.Mkdir(dst, 0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyTree(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+
+	case info.Mode().IsRegular():
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported file type: %s", src)
+	}
+
+	// Restore permissions and times after the contents are written
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,117 @@
+package fsutil
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// simulateCrossDevice makes rename fail with EXDEV during the test.
+func simulateCrossDevice(t *testing.T) {
+	t.Helper()
+
+	original := rename
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { rename = original })
+}
+
+func TestMove_CrossDeviceDirectory(t *testing.T) {
+	simulateCrossDevice(t)
+
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	script := filepath.Join(src, "sub", "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Symlink("sub/run.sh", filepath.Join(src, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	mtime := time.Date(2025, 12, 4, 10, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(script, mtime, mtime); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+
+	dst := filepath.Join(tmpDir, "dst")
+	if err := Move(src, dst); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	if _, err := os.Lstat(src); !os.IsNotExist(err) {
+		t.Error("Source still exists after move")
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "sub", "run.sh"))
+	if err != nil {
+		t.Fatalf("Failed to stat copied file: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mtime %v, got %v", mtime, info.ModTime())
+	}
+
+	target, err := os.Readlink(filepath.Join(dst, "link"))
+	if err != nil || target != "sub/run.sh" {
+		t.Errorf("Expected symlink to sub/run.sh, got %q (%v)", target, err)
+	}
+}
+
+func TestMove_SameDevice(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(src, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	if err := Move(src, filepath.Join(tmpDir, "b.txt")); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if err := Move(src, filepath.Join(tmpDir, "c.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for missing source, got: %v", err)
+	}
+}
+
+func TestCopy_RollsBackOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	// A socket cannot be copied, so the copy fails after a.txt
+	l, err := net.Listen("unix", filepath.Join(src, "z.sock"))
+	if err != nil {
+		t.Skipf("Unix sockets are not supported: %v", err)
+	}
+	defer l.Close()
+
+	dst := filepath.Join(tmpDir, "dst")
+	if err := Copy(src, dst); err == nil {
+		t.Fatal("Expected error for unsupported file type")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "src" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Expected only src to remain, got %v", names)
+	}
+}
@@ -0,0 +1,209 @@
+// Package ignore matches paths against gitignore-style patterns.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// FileName is the name of the ignore file read at the root of each hiden directory.
+const FileName = ".hidenignore"
+
+// rule is a compiled pattern.
+type rule struct {
+	re *regexp.Regexp
+	// negate re-includes the paths matched by a "!" pattern.
+	negate bool
+	// dirOnly matches directories only, for patterns with a trailing slash.
+	dirOnly bool
+}
+
+// Matcher reports whether paths are ignored. A nil Matcher ignores nothing.
+type Matcher struct {
+	rules []rule
+}
+
+// New compiles patterns written as lines of a .gitignore file:
+//   - blank lines and lines starting with # are skipped, and a leading \ escapes # or !
+//   - a leading ! re-includes paths ignored by an earlier pattern
+//   - a trailing / matches directories only
+//   - a pattern containing a / other than a trailing one is relative to the root;
+//     otherwise it matches at any depth
+//   - * and ? match within a path element, [...] matches a character class,
+//     and ** matches any number of path elements
+//
+// Patterns that cannot be compiled, such as [z-a], match nothing.
+func New(patterns []string) *Matcher {
+	return (*Matcher)(nil).With(patterns)
+}
+
+// With returns a Matcher applying patterns after the patterns of m,
+// so that they take precedence.
+func (m *Matcher) With(patterns []string) *Matcher {
+	n := &Matcher{}
+	if m != nil {
+		n.rules = append(n.rules, m.rules...)
+	}
+	for _, p := range patterns {
+		if r, ok := compile(p); ok {
+			n.rules = append(n.rules, r)
+		}
+	}
+	return n
+}
+
+// Match reports whether path, slash-separated and relative to the root, is ignored.
+// isDir tells whether path is a directory. The last matching pattern decides.
+// Match does not look at the parents of path: the caller is expected not to
+// descend into ignored directories.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := m.rules[i]
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(path) {
+			return !r.negate
+		}
+	}
+	return false
+}
+
+// ReadFile returns the lines of the ignore file at path.
+func ReadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// compile turns a pattern into a rule. It returns false for blank lines,
+// comments and invalid patterns.
+func compile(pattern string) (rule, bool) {
+	p := strings.TrimRight(pattern, "\r")
+	p = trimTrailingSpaces(p)
+	if p == "" || strings.HasPrefix(p, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\#`) || strings.HasPrefix(p, `\!`) {
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return rule{}, false
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if strings.Contains(p, "/") {
+		p = strings.TrimPrefix(p, "/")
+	} else {
+		// A pattern without a slash matches the name at any depth
+		b.WriteString("(?:.*/)?")
+	}
+	b.WriteString(globToRegexp(p))
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// trimTrailingSpaces removes the trailing spaces of p that are not escaped with a backslash.
+func trimTrailingSpaces(p string) string {
+	for strings.HasSuffix(p, " ") && !strings.HasSuffix(p, `\ `) {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// globToRegexp translates the glob p into a regular expression.
+func globToRegexp(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '*' && strings.HasPrefix(p[i:], "**"):
+			atStart := i == 0 || p[i-1] == '/'
+			rest := p[i+2:]
+			switch {
+			case atStart && strings.HasPrefix(rest, "/"):
+				// "**/" matches zero or more directories
+				b.WriteString("(?:.*/)?")
+				i += 2
+			case atStart && rest == "":
+				// A trailing "/**" matches everything inside
+				b.WriteString(".*")
+				i++
+			default:
+				b.WriteString("[^/]*")
+				i++
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := classEnd(p, i)
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := p[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		case c == '\\' && i+1 < len(p):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(p[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// classEnd returns the index of the ] closing the character class starting at p[start],
+// or -1 if it is not closed.
+func classEnd(p string, start int) int {
+	i := start + 1
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		i++
+	}
+	// A ] right after the opening bracket is part of the class
+	if i < len(p) && p[i] == ']' {
+		i++
+	}
+	for ; i < len(p); i++ {
+		if p[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,97 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		// A name matches at any depth
+		{[]string{"node_modules"}, "node_modules", true, true},
+		{[]string{"node_modules"}, "tools/node_modules", true, true},
+		{[]string{"*.log"}, "build/out.log", false, true},
+		{[]string{"*.log"}, "build/out.log.txt", false, false},
+		// A pattern with a slash is relative to the root
+		{[]string{"/build"}, "build", true, true},
+		{[]string{"/build"}, "src/build", true, false},
+		{[]string{"docs/*.md"}, "docs/a.md", false, true},
+		{[]string{"docs/*.md"}, "docs/sub/a.md", false, false},
+		{[]string{"docs/*.md"}, "x/docs/a.md", false, false},
+		// A trailing slash matches directories only
+		{[]string{"tmp/"}, "tmp", true, true},
+		{[]string{"tmp/"}, "tmp", false, false},
+		// ** matches any number of directories
+		{[]string{"**/cache"}, "cache", true, true},
+		{[]string{"**/cache"}, "a/b/cache", true, true},
+		{[]string{"a/**/b"}, "a/b", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+		{[]string{"out/**"}, "out/x/y", false, true},
+		{[]string{"out/**"}, "out", true, false},
+		// ? and character classes
+		{[]string{"?.txt"}, "a.txt", false, true},
+		{[]string{"?.txt"}, "ab.txt", false, false},
+		{[]string{"[ab].txt"}, "b.txt", false, true},
+		{[]string{"[!ab].txt"}, "b.txt", false, false},
+		{[]string{"[!ab].txt"}, "c.txt", false, true},
+		// The last matching pattern decides
+		{[]string{"*.md", "!keep.md"}, "keep.md", false, false},
+		{[]string{"*.md", "!keep.md"}, "drop.md", false, true},
+		{[]string{"!keep.md", "*.md"}, "keep.md", false, true},
+		// Comments, blank lines, escapes and trailing spaces
+		{[]string{"# comment", "", "   "}, "# comment", false, false},
+		{[]string{`\#notes`}, "#notes", false, true},
+		{[]string{`\!important`}, "!important", false, true},
+		{[]string{"secret.txt  "}, "secret.txt", false, true},
+		{[]string{`a\*b`}, "a*b", false, true},
+		{[]string{`a\*b`}, "axb", false, false},
+		// Invalid patterns match nothing
+		{[]string{"[z-a]"}, "b", false, false},
+		{[]string{"[abc"}, "[abc", false, true},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.patterns).Match(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("New(%q).Match(%q, %v): expected %v, got %v", tt.patterns, tt.path, tt.isDir, tt.expected, got)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	base := New([]string{"*.log"})
+	m := base.With([]string{"!debug.log", "*.tmp"})
+
+	if !m.Match("error.log", false) || m.Match("debug.log", false) || !m.Match("a.tmp", false) {
+		t.Error("Expected the appended patterns to take precedence")
+	}
+	if !base.Match("debug.log", false) || base.Match("a.tmp", false) {
+		t.Error("Expected the base matcher to be unchanged")
+	}
+
+	var nilMatcher *Matcher
+	if nilMatcher.Match("a.log", false) {
+		t.Error("Expected a nil matcher to ignore nothing")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("node_modules/\r\n# comment\n*.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to create ignore file: %v", err)
+	}
+
+	lines, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	m := New(lines)
+	if !m.Match("node_modules", true) || !m.Match("a/b.log", false) || m.Match("memo.md", false) {
+		t.Errorf("Unexpected matcher for %q", lines)
+	}
+}
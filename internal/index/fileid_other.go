@@ -0,0 +1,23 @@
+//go:build !unix
+
+package index
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileID identifies a file by its path with every symlink resolved,
+// since device and inode numbers are not available on this platform.
+type fileID struct {
+	path string
+}
+
+// idOf returns the identity of the file at path described by info.
+func idOf(path string, info os.FileInfo) (fileID, bool) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, false
+	}
+	return fileID{path: real}, true
+}
@@ -0,0 +1,541 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/qawatake/hiden/internal/ignore"
+	"github.com/sourcegraph/conc/pool"
+)
+
+// version is stored in the cache file; caches of other versions are discarded.
+const version = 3
+
+// mtimeGranularity is the coarsest mtime resolution expected from file
+// systems, such as NFS or SMB mounts with one second, or FAT with two.
+const mtimeGranularity = 2 * time.Second
+
+// File is an indexed file in a hiden directory.
+type File struct {
+	// Path is the path relative to the hiden directory.
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+}
+
+// dir is the listing of an indexed directory, reused while its mtime is unchanged.
+// A listing read within mtimeGranularity of the directory's mtime is not reused,
+// since a later change in the same tick leaves the mtime as it was.
+type dir struct {
+	ModTime time.Time `json:"mtime"`
+	// Files and Dirs are the names of the non-directory entries and subdirectories.
+	Files []string `json:"files"`
+	Dirs  []string `json:"dirs"`
+}
+
+// Repo is the indexed hiden directory of a repository.
+type Repo struct {
+	// Path is the absolute path of the repository.
+	Path string `json:"path"`
+	// RealPath is the absolute path of the hiden directory with symlinks resolved.
+	RealPath string `json:"real_path"`
+	Files    []File `json:"files"`
+	// Linked reports that the hiden directory is a symlink, e.g. to the
+	// hiden directory of another worktree.
+	Linked bool `json:"linked"`
+	// Scanned is when the scan that read the listings in Dirs started.
+	Scanned time.Time `json:"scanned"`
+	// Dirs holds the directory listings keyed by path relative to the hiden directory.
+	Dirs map[string]dir `json:"dirs"`
+}
+
+// Index holds the files in the hiden directories of repositories.
+type Index struct {
+	Version int    `json:"version"`
+	Dirname string `json:"dirname"`
+	// Repos holds the repositories that have a hiden directory, keyed by path.
+	Repos map[string]*Repo `json:"repos"`
+	// Ignore holds gitignore-style patterns of files and directories that
+	// Refresh leaves out, in addition to the .hidenignore file of each hiden directory.
+	Ignore []string `json:"-"`
+	// FollowSymlinks makes Refresh descend into symlinked directories (see ScanOptions).
+	FollowSymlinks bool `json:"-"`
+}
+
+// New returns an empty index of the hiden directories named dirname.
+func New(dirname string) *Index {
+	return &Index{Version: version, Dirname: dirname, Repos: make(map[string]*Repo)}
+}
+
+// Load reads the index cached at path. A missing, broken or outdated cache,
+// or one made for another dirname, yields an empty index.
+func Load(path, dirname string) *Index {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return New(dirname)
+	}
+
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil || ix.Version != version || ix.Dirname != dirname || ix.Repos == nil {
+		return New(dirname)
+	}
+	return &ix
+}
+
+// Save writes the index to path, replacing the file atomically.
+func (ix *Index) Save(path string) error {
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// SlowThreshold is the scan time above which a repository is reported as slow.
+const SlowThreshold = time.Second
+
+// Limits bounds the scanning done by Refresh.
+type Limits struct {
+	// Workers is the maximum number of repositories scanned at once. Zero means no limit.
+	Workers int
+	// RepoTimeout is the time after which the scan of a repository is abandoned. Zero means no limit.
+	RepoTimeout time.Duration
+}
+
+// Report describes the repositories that did not scan smoothly.
+type Report struct {
+	// Skipped lists the repositories whose scan was abandoned.
+	Skipped []Skipped
+	// Slow lists the repositories that took longer than SlowThreshold to scan.
+	Slow []Slow
+	// Errors lists the files and directories that could not be read.
+	Errors []ScanError
+}
+
+// Warnings returns the number of skipped repositories and unreadable files and directories.
+func (r Report) Warnings() int {
+	return len(r.Skipped) + len(r.Errors)
+}
+
+// ErrorKind classifies a ScanError.
+type ErrorKind string
+
+const (
+	// KindPermission is a file or directory that the user is not allowed to read.
+	KindPermission ErrorKind = "permission denied"
+	// KindBrokenSymlink is a symlink whose target does not exist.
+	KindBrokenSymlink ErrorKind = "broken symlink"
+	// KindIO is any other failure to read a file or directory.
+	KindIO ErrorKind = "I/O error"
+)
+
+// ScanError is a file or directory in a hiden directory that could not be read.
+type ScanError struct {
+	// Repo is the path of the repository.
+	Repo string
+	// Path is the absolute path of the file or directory.
+	Path string
+	Kind ErrorKind
+	Err  error
+}
+
+func (e ScanError) Error() string {
+	var pathErr *fs.PathError
+	if e.Kind == KindIO && errors.As(e.Err, &pathErr) {
+		return fmt.Sprintf("%s: %s: %v", e.Path, e.Kind, pathErr.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Kind)
+}
+
+func (e ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Skipped is a repository whose scan was abandoned. Its cached files are kept.
+type Skipped struct {
+	Path string
+	Err  error
+}
+
+// Slow is a repository that took long to scan.
+type Slow struct {
+	Path     string
+	Duration time.Duration
+}
+
+// result is the outcome of scanning a repository.
+type result struct {
+	path     string
+	repo     *Repo
+	errs     []ScanError
+	err      error
+	duration time.Duration
+}
+
+// Refresh rescans the hiden directories of repos in parallel, reusing the
+// listings of unchanged directories, and drops the repositories not in repos.
+// A repository whose scan exceeds limits.RepoTimeout, or is not done when ctx
+// is done, keeps its cached files and is reported as skipped.
+// found, if not nil, is called from the scanning goroutines as soon as each
+// repository is scanned, with a nil Repo when it has no hiden directory.
+func (ix *Index) Refresh(ctx context.Context, repos []string, limits Limits, found func(path string, r *Repo)) Report {
+	opts := ScanOptions{Ignore: ignore.New(ix.Ignore), FollowSymlinks: ix.FollowSymlinks}
+	p := pool.NewWithResults[result]()
+	if limits.Workers > 0 {
+		p = p.WithMaxGoroutines(limits.Workers)
+	}
+	for _, path := range repos {
+		prev := ix.Repos[path]
+		p.Go(func() result {
+			start := time.Now()
+			r, errs, err := scanContext(ctx, prev, path, ix.Dirname, opts, limits.RepoTimeout)
+			if err != nil {
+				r = prev
+			}
+			if found != nil {
+				found(path, r)
+			}
+			return result{path: path, repo: r, errs: errs, err: err, duration: time.Since(start)}
+		})
+	}
+
+	var report Report
+	ix.Repos = make(map[string]*Repo)
+	for _, res := range p.Wait() {
+		if res.repo != nil {
+			ix.Repos[res.path] = res.repo
+		}
+		report.Errors = append(report.Errors, res.errs...)
+		switch {
+		case res.err != nil:
+			report.Skipped = append(report.Skipped, Skipped{Path: res.path, Err: res.err})
+		case res.duration > SlowThreshold:
+			report.Slow = append(report.Slow, Slow{Path: res.path, Duration: res.duration})
+		}
+	}
+
+	slices.SortFunc(report.Skipped, func(a, b Skipped) int { return strings.Compare(a.Path, b.Path) })
+	slices.SortFunc(report.Slow, func(a, b Slow) int { return strings.Compare(a.Path, b.Path) })
+	slices.SortFunc(report.Errors, func(a, b ScanError) int { return strings.Compare(a.Path, b.Path) })
+	return report
+}
+
+// scan is the function run by scanContext, replaced in tests.
+var scan = Scan
+
+// scanContext runs Scan, giving up when ctx is done or after timeout unless it is zero.
+// A scan stuck in a system call, e.g. on an unresponsive network file system,
+// is left running in the background.
+func scanContext(ctx context.Context, prev *Repo, path, dirname string, opts ScanOptions, timeout time.Duration) (*Repo, []ScanError, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("scan cancelled: %w", err)
+	}
+
+	repoCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		repoCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type scanned struct {
+		repo *Repo
+		errs []ScanError
+	}
+	done := make(chan scanned, 1)
+	// The goroutine may outlive the call, so it must not read scan itself
+	fn := scan
+	go func() {
+		r, errs := fn(repoCtx, prev, path, dirname, opts)
+		done <- scanned{r, errs}
+	}()
+
+	var res scanned
+	select {
+	case res = <-done:
+	case <-repoCtx.Done():
+	}
+	// A scan that returns after the deadline may be incomplete
+	if err := repoCtx.Err(); err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
+		}
+		return nil, nil, fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return res.repo, res.errs, nil
+}
+
+// ScanOptions configures Scan.
+type ScanOptions struct {
+	// Ignore leaves out matching files and directories, in addition to the
+	// patterns in the .hidenignore file at the root of the hiden directory.
+	Ignore *ignore.Matcher
+	// FollowSymlinks descends into symlinked directories and lists symlinked
+	// files with the modification time and size of their target. Directories
+	// already walked are not walked again, which stops symlink cycles, and a
+	// file reachable by several paths is listed once, preferably by a path
+	// without symlinks.
+	FollowSymlinks bool
+}
+
+// Scan indexes the hiden directory named dirname of the repository at path.
+// The listings in prev are reused for directories whose mtime is unchanged,
+// so only the files in them are stat'ed. Listings whose mtime was within
+// mtimeGranularity of the previous scan are "racily clean", as git calls
+// them, and read again. Returns nil when the repository has
+// no hiden directory. Unreadable files and directories are skipped and
+// returned as ScanErrors; broken symlinks are listed but also returned.
+// Files and directories matched by the ignore patterns are left out, and
+// ignored directories are not read.
+// Scan stops descending into directories once ctx is done.
+func Scan(ctx context.Context, prev *Repo, path, dirname string, opts ScanOptions) (*Repo, []ScanError) {
+	start := time.Now()
+	hidenDir := filepath.Join(path, dirname)
+	info, err := os.Stat(hidenDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			if _, lerr := os.Lstat(hidenDir); lerr == nil {
+				return nil, []ScanError{{Repo: path, Path: hidenDir, Kind: KindBrokenSymlink, Err: err}}
+			}
+			return nil, nil
+		}
+		return nil, []ScanError{newScanError(path, hidenDir, err)}
+	}
+	if !info.IsDir() {
+		return nil, nil
+	}
+
+	// Resolve symlink if necessary
+	resolvedHidenDir, err := filepath.EvalSymlinks(hidenDir)
+	if err != nil {
+		// If we can't resolve the symlink, fall back to the original path
+		resolvedHidenDir = hidenDir
+	}
+
+	w := &walker{
+		ctx:    ctx,
+		repo:   &Repo{Path: path, RealPath: resolvedHidenDir, Linked: isSymlink(hidenDir), Scanned: start, Dirs: make(map[string]dir)},
+		ign:    opts.Ignore,
+		follow: opts.FollowSymlinks,
+		dirs:   make(map[fileID]bool),
+		files:  make(map[fileID]listed),
+	}
+	if prev != nil {
+		w.cached = prev.Dirs
+		w.scanned = prev.Scanned
+	}
+	ignoreFile := filepath.Join(resolvedHidenDir, ignore.FileName)
+	if lines, err := ignore.ReadFile(ignoreFile); err == nil {
+		w.ign = w.ign.With(lines)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		w.errs = append(w.errs, newScanError(path, ignoreFile, err))
+	}
+
+	w.visit(resolvedHidenDir, info)
+	w.walk(resolvedHidenDir, ".", info, false)
+	return w.repo, w.errs
+}
+
+// isSymlink reports whether the file at path is a symlink.
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+// newScanError classifies err, the failure to read the file or directory at
+// path in the repository repo.
+func newScanError(repo, path string, err error) ScanError {
+	kind := KindIO
+	if errors.Is(err, fs.ErrPermission) {
+		kind = KindPermission
+	}
+	return ScanError{Repo: repo, Path: path, Kind: kind, Err: err}
+}
+
+// listed is a file listed by a walker.
+type listed struct {
+	// index is the index of the file in Repo.Files.
+	index int
+	// linked reports that the file was reached through a symlink.
+	linked bool
+}
+
+// walker holds the state of a Scan.
+type walker struct {
+	ctx    context.Context
+	repo   *Repo
+	cached map[string]dir
+	// scanned is when the cached listings were read.
+	scanned time.Time
+	ign     *ignore.Matcher
+	follow  bool
+	errs    []ScanError
+	// dirs holds the directories walked so far when following symlinks.
+	dirs map[fileID]bool
+	// files holds the files listed so far when following symlinks.
+	files map[fileID]listed
+}
+
+// visit records the directory at path as walked. It returns false if it
+// already was, or if it cannot be identified.
+func (w *walker) visit(path string, info os.FileInfo) bool {
+	if !w.follow {
+		return true
+	}
+	id, ok := idOf(path, info)
+	if !ok || w.dirs[id] {
+		return false
+	}
+	w.dirs[id] = true
+	return true
+}
+
+// add lists the file at abs, whose path relative to the hiden directory is rel.
+// When following symlinks, a file already listed is only replaced by a path
+// without symlinks.
+func (w *walker) add(abs, rel string, info os.FileInfo, linked bool) {
+	f := File{Path: rel, ModTime: info.ModTime(), Size: info.Size()}
+	if w.follow {
+		if id, ok := idOf(abs, info); ok {
+			if prev, ok := w.files[id]; ok {
+				if prev.linked && !linked {
+					w.repo.Files[prev.index] = f
+					w.files[id] = listed{index: prev.index}
+				}
+				return
+			}
+			w.files[id] = listed{index: len(w.repo.Files), linked: linked}
+		}
+	}
+	w.repo.Files = append(w.repo.Files, f)
+}
+
+// racy reports whether the cached listing d may miss changes made in the
+// same mtime tick as it was read.
+func (w *walker) racy(d dir) bool {
+	return !d.ModTime.Before(w.scanned.Add(-mtimeGranularity))
+}
+
+// walk indexes the directory at abs, whose path relative to the hiden directory is rel.
+// linked reports that the directory was reached through a symlink.
+// Files and directories matched by the ignore patterns are skipped, and those
+// that cannot be read are recorded as ScanErrors.
+func (w *walker) walk(abs, rel string, info os.FileInfo, linked bool) {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	d, ok := w.cached[rel]
+	if !ok || !d.ModTime.Equal(info.ModTime()) || w.racy(d) {
+		entries, err := os.ReadDir(abs)
+		if err != nil {
+			w.errs = append(w.errs, newScanError(w.repo.Path, abs, err))
+			return
+		}
+		d = dir{ModTime: info.ModTime()}
+		for _, e := range entries {
+			if e.IsDir() {
+				d.Dirs = append(d.Dirs, e.Name())
+			} else {
+				d.Files = append(d.Files, e.Name())
+			}
+		}
+	}
+	w.repo.Dirs[rel] = d
+
+	// Symlinked directories are walked after the others, so that files are
+	// listed by their path without symlinks when they can be
+	var linkedDirs []string
+	for _, name := range d.Files {
+		if w.ign.Match(filepath.ToSlash(filepath.Join(rel, name)), false) {
+			continue
+		}
+		path := filepath.Join(abs, name)
+		info, err := os.Lstat(path)
+		if err != nil {
+			// A file removed since the directory was read is not an error
+			if !errors.Is(err, fs.ErrNotExist) {
+				w.errs = append(w.errs, newScanError(w.repo.Path, path, err))
+			}
+			continue
+		}
+		fileLinked := linked
+		if info.Mode()&fs.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				w.errs = append(w.errs, ScanError{Repo: w.repo.Path, Path: path, Kind: KindBrokenSymlink, Err: err})
+			case err != nil:
+				w.errs = append(w.errs, newScanError(w.repo.Path, path, err))
+			case w.follow && target.IsDir():
+				linkedDirs = append(linkedDirs, name)
+				continue
+			case w.follow:
+				info = target
+				fileLinked = true
+			}
+		}
+		w.add(path, filepath.Join(rel, name), info, fileLinked)
+	}
+
+	for _, name := range d.Dirs {
+		w.walkDir(abs, rel, name, linked, os.Lstat)
+	}
+	for _, name := range linkedDirs {
+		w.walkDir(abs, rel, name, true, os.Stat)
+	}
+}
+
+// walkDir walks the subdirectory name of the directory at abs, stat'ing it with stat.
+func (w *walker) walkDir(abs, rel, name string, linked bool, stat func(string) (os.FileInfo, error)) {
+	subRel := filepath.Join(rel, name)
+	// Prune ignored directories without reading them
+	if w.ign.Match(filepath.ToSlash(subRel), true) {
+		return
+	}
+	sub := filepath.Join(abs, name)
+	info, err := stat(sub)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			w.errs = append(w.errs, newScanError(w.repo.Path, sub, err))
+		}
+		return
+	}
+	if !info.IsDir() {
+		return
+	}
+	// Directories reached through symlinks are walked only once, which also stops cycles
+	if !w.visit(sub, info) && linked {
+		return
+	}
+	w.walk(sub, subRel, info, linked)
+}
@@ -0,0 +1,378 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+}
+
+func paths(r *Repo) map[string]bool {
+	found := make(map[string]bool)
+	for _, f := range r.Files {
+		found[f.Path] = true
+	}
+	return found
+}
+
+func TestScan_ReusesUnchangedDirectories(t *testing.T) {
+	repoDir := t.TempDir()
+	hidenDir := filepath.Join(repoDir, ".hiden")
+	writeFile(t, filepath.Join(hidenDir, "a.txt"))
+	writeFile(t, filepath.Join(hidenDir, "sub", "b.txt"))
+	// Listings of directories modified around the scan are not reused
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(hidenDir, "sub"), old, old); err != nil {
+		t.Fatalf("Failed to change mtime: %v", err)
+	}
+
+	prev, _ := Scan(context.Background(), nil, repoDir, ".hiden", ScanOptions{})
+	if prev == nil || len(prev.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %+v", prev)
+	}
+
+	// A file added to a directory whose mtime is then restored is not listed again,
+	// but a modified file in it is stat'ed again
+	if err := os.Chtimes(filepath.Join(hidenDir, "sub", "b.txt"), old, old); err != nil {
+		t.Fatalf("Failed to change mtime: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(hidenDir, "sub"))
+	if err != nil {
+		t.Fatalf("Failed to stat dir: %v", err)
+	}
+	writeFile(t, filepath.Join(hidenDir, "sub", "c.txt"))
+	if err := os.Chtimes(filepath.Join(hidenDir, "sub"), info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to change mtime: %v", err)
+	}
+	// A file added to a directory with a new mtime is found
+	writeFile(t, filepath.Join(hidenDir, "d.txt"))
+	if err := os.Chtimes(hidenDir, time.Now().Add(time.Hour), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to change mtime: %v", err)
+	}
+
+	r, _ := Scan(context.Background(), prev, repoDir, ".hiden", ScanOptions{})
+	found := paths(r)
+	if len(found) != 3 || !found["a.txt"] || !found[filepath.Join("sub", "b.txt")] || !found["d.txt"] {
+		t.Errorf("Expected a.txt, sub/b.txt and d.txt, got %v", found)
+	}
+	for _, f := range r.Files {
+		if f.Path == filepath.Join("sub", "b.txt") && !f.ModTime.Equal(old) {
+			t.Errorf("Expected updated mtime %v, got %v", old, f.ModTime)
+		}
+	}
+
+	// A full scan finds every file
+	if r, _ := Scan(context.Background(), nil, repoDir, ".hiden", ScanOptions{}); len(paths(r)) != 4 {
+		t.Errorf("Expected 4 files, got %v", paths(r))
+	}
+}
+
+func TestScan_RacilyClean(t *testing.T) {
+	repoDir := t.TempDir()
+	hidenDir := filepath.Join(repoDir, ".hiden")
+	writeFile(t, filepath.Join(hidenDir, "a.txt"))
+	info, err := os.Stat(hidenDir)
+	if err != nil {
+		t.Fatalf("Failed to stat dir: %v", err)
+	}
+
+	prev, _ := Scan(context.Background(), nil, repoDir, ".hiden", ScanOptions{})
+	if prev == nil || len(prev.Files) != 1 {
+		t.Fatalf("Expected 1 file, got %+v", prev)
+	}
+
+	// A file created in the same mtime tick as the listing was read leaves
+	// the mtime of the directory unchanged
+	writeFile(t, filepath.Join(hidenDir, "b.txt"))
+	if err := os.Chtimes(hidenDir, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to change mtime: %v", err)
+	}
+
+	r, _ := Scan(context.Background(), prev, repoDir, ".hiden", ScanOptions{})
+	if found := paths(r); len(found) != 2 || !found["b.txt"] {
+		t.Errorf("Expected a.txt and b.txt, got %v", found)
+	}
+}
+
+func TestIndex_SaveLoad(t *testing.T) {
+	withHiden := t.TempDir()
+	writeFile(t, filepath.Join(withHiden, ".hiden", "a.txt"))
+	withoutHiden := t.TempDir()
+
+	ix := New(".hiden")
+	ix.Refresh(context.Background(), []string{withHiden, withoutHiden}, Limits{}, nil)
+	if len(ix.Repos) != 1 || ix.Repos[withHiden] == nil {
+		t.Fatalf("Expected only %s in the index, got %v", withHiden, ix.Repos)
+	}
+
+	path := filepath.Join(t.TempDir(), "cache", "index.json")
+	if err := ix.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded := Load(path, ".hiden")
+	if r := loaded.Repos[withHiden]; r == nil || len(r.Files) != 1 || r.Files[0].Path != "a.txt" {
+		t.Errorf("Unexpected loaded index: %+v", loaded.Repos)
+	}
+
+	// A cache for another dirname is discarded
+	if other := Load(path, ".memo"); len(other.Repos) != 0 {
+		t.Errorf("Expected empty index for another dirname, got %v", other.Repos)
+	}
+
+	// Repositories no longer listed are dropped
+	loaded.Refresh(context.Background(), nil, Limits{}, nil)
+	if len(loaded.Repos) != 0 {
+		t.Errorf("Expected empty index, got %v", loaded.Repos)
+	}
+}
+
+func TestRefresh_Cancelled(t *testing.T) {
+	repoDir := t.TempDir()
+	writeFile(t, filepath.Join(repoDir, ".hiden", "a.txt"))
+
+	ix := New(".hiden")
+	if report := ix.Refresh(context.Background(), []string{repoDir}, Limits{Workers: 1}, nil); len(report.Skipped) != 0 {
+		t.Fatalf("Expected nothing skipped, got %v", report.Skipped)
+	}
+	writeFile(t, filepath.Join(repoDir, ".hiden", "b.txt"))
+
+	// A repository that cannot be scanned keeps its cached files and is reported
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := ix.Refresh(ctx, []string{repoDir}, Limits{Workers: 1, RepoTimeout: time.Second}, nil)
+	if len(report.Skipped) != 1 || report.Skipped[0].Path != repoDir || !errors.Is(report.Skipped[0].Err, context.Canceled) {
+		t.Errorf("Expected %s to be skipped as cancelled, got %v", repoDir, report.Skipped)
+	}
+	if r := ix.Repos[repoDir]; r == nil || len(r.Files) != 1 || r.Files[0].Path != "a.txt" {
+		t.Errorf("Expected the cached files to be kept, got %+v", ix.Repos)
+	}
+}
+
+// stubScan replaces the scan run by Refresh with fn until the test ends.
+func stubScan(t *testing.T, fn func(ctx context.Context, prev *Repo, path, dirname string, opts ScanOptions) (*Repo, []ScanError)) {
+	t.Helper()
+	orig := scan
+	scan = fn
+	t.Cleanup(func() { scan = orig })
+}
+
+func TestRefresh_RepoTimeout(t *testing.T) {
+	stuck, done := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(stuck, ".hiden", "a.txt"))
+	writeFile(t, filepath.Join(done, ".hiden", "b.txt"))
+
+	ix := New(".hiden")
+	ix.Refresh(context.Background(), []string{stuck, done}, Limits{}, nil)
+
+	// A scan stuck in a system call does not return when its context is done
+	release := make(chan struct{})
+	defer close(release)
+	stubScan(t, func(ctx context.Context, prev *Repo, path, dirname string, opts ScanOptions) (*Repo, []ScanError) {
+		if path == stuck {
+			<-release
+		}
+		return Scan(ctx, prev, path, dirname, opts)
+	})
+
+	report := ix.Refresh(context.Background(), []string{stuck, done}, Limits{RepoTimeout: 20 * time.Millisecond}, nil)
+	if len(report.Skipped) != 1 || report.Skipped[0].Path != stuck || !errors.Is(report.Skipped[0].Err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %s to be skipped as timed out, got %v", stuck, report.Skipped)
+	}
+	if msg := report.Skipped[0].Err.Error(); !strings.HasPrefix(msg, "timed out after 20ms") {
+		t.Errorf("Unexpected error %q", msg)
+	}
+	if r := ix.Repos[stuck]; r == nil || len(r.Files) != 1 || r.Files[0].Path != "a.txt" {
+		t.Errorf("Expected the cached files to be kept, got %+v", ix.Repos[stuck])
+	}
+	if r := ix.Repos[done]; r == nil || len(r.Files) != 1 {
+		t.Errorf("Expected %s to be scanned, got %+v", done, r)
+	}
+}
+
+func TestRefresh_Workers(t *testing.T) {
+	var repos []string
+	for range 6 {
+		repos = append(repos, t.TempDir())
+	}
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	stubScan(t, func(ctx context.Context, prev *Repo, path, dirname string, opts ScanOptions) (*Repo, []ScanError) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil
+	})
+
+	tests := []struct {
+		workers  int
+		min, max int
+	}{
+		{2, 2, 2},
+		// Zero means no limit
+		{0, 3, len(repos)},
+	}
+	for _, tt := range tests {
+		peak = 0
+		New(".hiden").Refresh(context.Background(), repos, Limits{Workers: tt.workers}, nil)
+		if peak < tt.min || peak > tt.max {
+			t.Errorf("Workers %d: expected %d to %d scans at a time, got %d", tt.workers, tt.min, tt.max, peak)
+		}
+	}
+}
+
+func TestScan_Errors(t *testing.T) {
+	repoDir := t.TempDir()
+	hidenDir := filepath.Join(repoDir, ".hiden")
+	writeFile(t, filepath.Join(hidenDir, "a.txt"))
+	writeFile(t, filepath.Join(hidenDir, "locked", "b.txt"))
+	if err := os.Symlink(filepath.Join(hidenDir, "missing.txt"), filepath.Join(hidenDir, "broken.txt")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	want := map[string]ErrorKind{
+		filepath.Join(hidenDir, "broken.txt"): KindBrokenSymlink,
+	}
+	wantFiles := 3
+	// Permissions are not enforced for root
+	if os.Getuid() != 0 {
+		locked := filepath.Join(hidenDir, "locked")
+		if err := os.Chmod(locked, 0); err != nil {
+			t.Fatalf("Failed to change permissions: %v", err)
+		}
+		t.Cleanup(func() { os.Chmod(locked, 0755) })
+		want[locked] = KindPermission
+		wantFiles = 2
+	}
+
+	r, errs := Scan(context.Background(), nil, repoDir, ".hiden", ScanOptions{})
+	if found := paths(r); len(found) != wantFiles || !found["a.txt"] || !found["broken.txt"] {
+		t.Errorf("Expected %d files including a.txt and broken.txt, got %v", wantFiles, found)
+	}
+
+	kinds := make(map[string]ErrorKind)
+	for _, e := range errs {
+		if e.Repo != repoDir {
+			t.Errorf("Expected repo %s, got %s", repoDir, e.Repo)
+		}
+		kinds[e.Path] = e.Kind
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("Expected errors %v, got %v", want, errs)
+	}
+	for path, kind := range want {
+		if kinds[path] != kind {
+			t.Errorf("Expected %s for %s, got %q", kind, path, kinds[path])
+		}
+	}
+
+	// Refresh reports the errors of every repository
+	report := New(".hiden").Refresh(context.Background(), []string{repoDir}, Limits{}, nil)
+	if report.Warnings() != len(want) {
+		t.Errorf("Expected %d warnings, got %+v", len(want), report)
+	}
+}
+
+func TestRefresh_Ignore(t *testing.T) {
+	repoDir := t.TempDir()
+	hidenDir := filepath.Join(repoDir, ".hiden")
+	writeFile(t, filepath.Join(hidenDir, "memo.md"))
+	writeFile(t, filepath.Join(hidenDir, "debug.log"))
+	writeFile(t, filepath.Join(hidenDir, "keep.log"))
+	writeFile(t, filepath.Join(hidenDir, "tool", "node_modules", "pkg", "index.js"))
+	writeFile(t, filepath.Join(hidenDir, "tool", "main.js"))
+	writeFile(t, filepath.Join(hidenDir, "build", "out.bin"))
+	if err := os.WriteFile(filepath.Join(hidenDir, ".hidenignore"), []byte("# artefacts\n/build/\n!keep.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to create ignore file: %v", err)
+	}
+
+	ix := New(".hiden")
+	ix.Ignore = []string{"node_modules/", "*.log"}
+	ix.Refresh(context.Background(), []string{repoDir}, Limits{}, nil)
+
+	r := ix.Repos[repoDir]
+	found := paths(r)
+	want := []string{".hidenignore", "memo.md", "keep.log", filepath.Join("tool", "main.js")}
+	if len(found) != len(want) {
+		t.Errorf("Expected %v, got %v", want, found)
+	}
+	for _, p := range want {
+		if !found[p] {
+			t.Errorf("Expected %s to be listed, got %v", p, found)
+		}
+	}
+
+	// Ignored directories are not read
+	for _, rel := range []string{filepath.Join("tool", "node_modules"), "build"} {
+		if _, ok := r.Dirs[rel]; ok {
+			t.Errorf("Expected %s to be pruned", rel)
+		}
+	}
+}
+
+func TestScan_FollowSymlinks(t *testing.T) {
+	repoDir := t.TempDir()
+	hidenDir := filepath.Join(repoDir, ".hiden")
+	shared := filepath.Join(t.TempDir(), "scripts")
+	writeFile(t, filepath.Join(hidenDir, "a.md"))
+	writeFile(t, filepath.Join(hidenDir, "sub", "b.md"))
+	writeFile(t, filepath.Join(shared, "deploy.sh"))
+	writeFile(t, filepath.Join(shared, "lib", "util.sh"))
+	links := map[string]string{
+		// Two links to the same directory
+		"shared":   shared,
+		"z-shared": shared,
+		// A cycle back to the hiden directory
+		"loop": ".",
+		// A second path to a file in the hiden directory
+		"alias.md": filepath.Join("sub", "b.md"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(hidenDir, name)); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+	}
+
+	// Without following, symlinks are listed as files
+	r, _ := Scan(context.Background(), nil, repoDir, ".hiden", ScanOptions{})
+	if found := paths(r); len(found) != 6 || !found["shared"] || !found["loop"] {
+		t.Errorf("Expected the symlinks to be listed as files, got %v", found)
+	}
+
+	r, errs := Scan(context.Background(), nil, repoDir, ".hiden", ScanOptions{FollowSymlinks: true})
+	if len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	found := paths(r)
+	want := []string{"a.md", filepath.Join("sub", "b.md"), filepath.Join("shared", "deploy.sh"), filepath.Join("shared", "lib", "util.sh")}
+	if len(found) != len(want) {
+		t.Errorf("Expected %v, got %v", want, found)
+	}
+	for _, p := range want {
+		if !found[p] {
+			t.Errorf("Expected %s to be listed, got %v", p, found)
+		}
+	}
+}
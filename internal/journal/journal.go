@@ -0,0 +1,165 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Operations recorded in the journal.
+const (
+	// OpMove is a file moved into a hiden directory.
+	OpMove = "mv"
+	// OpCopy is a file copied. Undoing it removes the copy.
+	OpCopy = "cp"
+	// OpLink is a symlink created to a file. Undoing it removes the symlink.
+	OpLink = "ln"
+	// OpBackup is an existing destination renamed to a numbered backup.
+	OpBackup = "backup"
+	// OpRestore is a file moved back from a hiden directory.
+	// A copy made by hiden restore --copy is an OpCopy.
+	OpRestore = "restore"
+	// OpMkdir is a directory created by hiden. Src is empty.
+	OpMkdir = "mkdir"
+	// OpUndo is an operation reverted by hiden undo. Undone is the reverted batch.
+	OpUndo = "undo"
+)
+
+// Entry is a file operation recorded in the journal.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Batch identifies the command invocation that made the operation.
+	Batch string `json:"batch"`
+	// Command is the hiden command that made the operation, such as "mv".
+	Command string `json:"command"`
+	Op      string `json:"op"`
+	// Src is the absolute path the file was at before the operation.
+	Src string `json:"src,omitempty"`
+	// Dst is the absolute path the file is at after the operation.
+	Dst string `json:"dst"`
+	// Size and ModTime are those of Dst right after the operation,
+	// used to detect changes before undoing it.
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	// Undone is the batch reverted by an OpUndo entry.
+	Undone string `json:"undone,omitempty"`
+}
+
+// NewEntry returns an entry for the operation op that put the file at dst,
+// recording the current size and modification time of dst.
+func NewEntry(op, src, dst string) (Entry, error) {
+	info, err := os.Lstat(dst)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Time:    time.Now(),
+		Op:      op,
+		Src:     src,
+		Dst:     dst,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// Journal is an append-only log of file operations stored as JSON lines.
+type Journal struct {
+	path    string
+	command string
+	batch   string
+}
+
+// Open returns the journal stored at path. The file is created on the first Append.
+// Entries appended through the returned Journal belong to a new batch made by command.
+func Open(path, command string) *Journal {
+	return &Journal{
+		path:    path,
+		command: command,
+		batch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// Batch returns the identifier of the batch entries are appended to.
+func (j *Journal) Batch() string {
+	return j.batch
+}
+
+// Append adds entries to the end of the journal, in the batch of j.
+func (j *Journal) Append(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	// Write all entries at once so that concurrent writers do not interleave them
+	var b []byte
+	for _, e := range entries {
+		e.Batch = j.batch
+		e.Command = j.command
+		line, err := json.Marshal(e)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		b = append(append(b, line...), '\n')
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return f.Close()
+}
+
+// Entries returns all entries in the order they were appended.
+// A missing journal has no entries. Lines that cannot be parsed are skipped.
+func (j *Journal) Entries() ([]Entry, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
+
+// Origin returns the path the file at path was moved from by the latest move to path.
+func (j *Journal) Origin(path string) (string, bool, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return "", false, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.Op == OpMove && e.Dst == path {
+			return e.Src, true, nil
+		}
+	}
+	return "", false, nil
+}
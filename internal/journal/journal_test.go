@@ -0,0 +1,61 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal_Origin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal.jsonl")
+	j := Open(path, "mv")
+
+	entries, err := j.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries in a missing journal, got %v", entries)
+	}
+
+	now := time.Now()
+	if err := j.Append(
+		Entry{Time: now, Op: OpMove, Src: "/repo/a.md", Dst: "/repo/.hiden/a.md"},
+		Entry{Time: now, Op: OpRestore, Src: "/repo/.hiden/a.md", Dst: "/repo/a.md"},
+	); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := j.Append(Entry{Time: now, Op: OpMove, Src: "/repo/docs/a.md", Dst: "/repo/.hiden/a.md"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// A broken line does not hide the others
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	f.WriteString("{broken\n")
+	f.Close()
+
+	entries, err = j.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	// The latest move wins
+	src, ok, err := j.Origin("/repo/.hiden/a.md")
+	if err != nil {
+		t.Fatalf("Origin failed: %v", err)
+	}
+	if !ok || src != "/repo/docs/a.md" {
+		t.Errorf("Expected /repo/docs/a.md, got %q (found: %v)", src, ok)
+	}
+
+	if _, ok, _ := j.Origin("/repo/.hiden/b.md"); ok {
+		t.Error("Expected no origin for a file never moved")
+	}
+}
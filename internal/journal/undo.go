@@ -0,0 +1,148 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/qawatake/hiden/internal/fsutil"
+)
+
+// ErrNothingToUndo is returned by Undo when every batch has been undone.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrChanged is returned by Undo when a file changed after the batch.
+var ErrChanged = errors.New("file changed since it was recorded")
+
+// LastBatch returns the entries of the latest batch that is neither an undo nor undone.
+func LastBatch(entries []Entry) []Entry {
+	undone := make(map[string]bool)
+	for _, e := range entries {
+		if e.Op == OpUndo {
+			undone[e.Batch] = true
+			undone[e.Undone] = true
+		}
+	}
+
+	var batch []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Batch == "" || undone[e.Batch] {
+			continue
+		}
+		if len(batch) > 0 && e.Batch != batch[0].Batch {
+			break
+		}
+		batch = append([]Entry{e}, batch...)
+	}
+	return batch
+}
+
+// Undo reverts the latest batch not yet undone and records the reverted
+// operations in the batch of j. Returns the entries of the reverted batch.
+// Every file is checked before anything is changed: if a file was modified or
+// removed, or its original path is taken, Undo fails with ErrChanged.
+// Directories created by the batch are removed only when empty.
+func (j *Journal) Undo() ([]Entry, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return nil, err
+	}
+	batch := LastBatch(entries)
+	if len(batch) == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	if err := check(batch); err != nil {
+		return nil, err
+	}
+
+	var records []Entry
+	var errs []error
+	for i := len(batch) - 1; i >= 0; i-- {
+		e := batch[i]
+		record := Entry{Time: time.Now(), Op: OpUndo, Src: e.Dst, Dst: e.Src, Undone: e.Batch}
+
+		if e.Op == OpMkdir {
+			// Keep directories that hold files not moved by the batch
+			if err := os.Remove(e.Dst); err == nil {
+				records = append(records, record)
+			}
+			continue
+		}
+
+		if err := revert(e); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if e.Op == OpCopy || e.Op == OpLink {
+			record.Dst = ""
+		} else if info, err := os.Lstat(e.Src); err == nil {
+			record.Size = info.Size()
+			record.ModTime = info.ModTime()
+		}
+		records = append(records, record)
+	}
+
+	if len(records) == 0 && len(errs) == 0 {
+		// Mark the batch as undone even if only non-empty directories were kept
+		records = append(records, Entry{Time: time.Now(), Op: OpUndo, Undone: batch[0].Batch})
+	}
+	if err := j.Append(records...); err != nil {
+		errs = append(errs, err)
+	}
+	return batch, errors.Join(errs...)
+}
+
+// revert undoes the operation of e, other than OpMkdir.
+func revert(e Entry) error {
+	if e.Op == OpCopy || e.Op == OpLink {
+		// The original is still in place
+		if err := os.RemoveAll(e.Dst); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", e.Dst, err)
+		}
+		return nil
+	}
+	if err := fsutil.Move(e.Dst, e.Src); err != nil {
+		return fmt.Errorf("failed to move %s back to %s: %w", e.Dst, e.Src, err)
+	}
+	return nil
+}
+
+// check reports whether batch can be reverted.
+func check(batch []Entry) error {
+	// taken tracks the paths freed or taken by the reverts checked so far
+	taken := make(map[string]bool)
+	exists := func(path string) bool {
+		if t, ok := taken[path]; ok {
+			return t
+		}
+		_, err := os.Lstat(path)
+		return err == nil
+	}
+
+	for i := len(batch) - 1; i >= 0; i-- {
+		e := batch[i]
+		if e.Op == OpMkdir {
+			continue
+		}
+
+		info, err := os.Lstat(e.Dst)
+		if err != nil {
+			return fmt.Errorf("%w: %s was removed", ErrChanged, e.Dst)
+		}
+		if info.Size() != e.Size || !info.ModTime().Equal(e.ModTime) {
+			return fmt.Errorf("%w: %s was modified", ErrChanged, e.Dst)
+		}
+		taken[e.Dst] = false
+		if e.Op == OpCopy || e.Op == OpLink {
+			continue
+		}
+		if exists(e.Src) {
+			return fmt.Errorf("%w: %s already exists", ErrChanged, e.Src)
+		}
+		taken[e.Src] = true
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// move renames src to dst and records it in j like hiden mv.
+func move(t *testing.T, j *Journal, op, src, dst string) {
+	t.Helper()
+
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatalf("Failed to move %s: %v", src, err)
+	}
+	e, err := NewEntry(op, src, dst)
+	if err != nil {
+		t.Fatalf("NewEntry failed: %v", err)
+	}
+	if err := j.Append(e); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	// First batch: a.md is moved
+	writeFile(t, filepath.Join(dir, "a.md"), "a")
+	first := Open(path, "mv")
+	move(t, first, OpMove, filepath.Join(dir, "a.md"), filepath.Join(dir, "moved-a.md"))
+
+	// Second batch: a directory is created and b.md replaces moved-a.md, which is backed up
+	time.Sleep(time.Millisecond)
+	second := Open(path, "mv")
+	hidenDir := filepath.Join(dir, ".hiden")
+	if err := os.Mkdir(hidenDir, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	e, err := NewEntry(OpMkdir, "", hidenDir)
+	if err != nil {
+		t.Fatalf("NewEntry failed: %v", err)
+	}
+	if err := second.Append(e); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "b.md"), "b")
+	move(t, second, OpBackup, filepath.Join(dir, "moved-a.md"), filepath.Join(dir, "moved-a.md.~1~"))
+	move(t, second, OpMove, filepath.Join(dir, "b.md"), filepath.Join(dir, "moved-a.md"))
+
+	undo := Open(path, "undo")
+	batch, err := undo.Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(batch) != 3 || batch[0].Batch != second.Batch() {
+		t.Errorf("Expected the 3 entries of the second batch, got %+v", batch)
+	}
+	for name, expected := range map[string]string{"b.md": "b", "moved-a.md": "a"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(content) != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", name, expected, string(content), err)
+		}
+	}
+	if _, err := os.Stat(hidenDir); !os.IsNotExist(err) {
+		t.Error("Created directory was not removed")
+	}
+
+	// The next undo reverts the first batch
+	batch, err = Open(path, "undo").Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(batch) != 1 || batch[0].Batch != first.Batch() {
+		t.Errorf("Expected the first batch, got %+v", batch)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.md")); err != nil {
+		t.Errorf("a.md was not moved back: %v", err)
+	}
+
+	if _, err := Open(path, "undo").Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected ErrNothingToUndo, got: %v", err)
+	}
+}
+
+func TestUndo_Changed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	writeFile(t, filepath.Join(dir, "a.md"), "a")
+	writeFile(t, filepath.Join(dir, "b.md"), "b")
+	j := Open(path, "mv")
+	move(t, j, OpMove, filepath.Join(dir, "a.md"), filepath.Join(dir, "moved-a.md"))
+	move(t, j, OpMove, filepath.Join(dir, "b.md"), filepath.Join(dir, "moved-b.md"))
+
+	// moved-b.md is edited after the move
+	writeFile(t, filepath.Join(dir, "moved-b.md"), "edited")
+
+	if _, err := Open(path, "undo").Undo(); !errors.Is(err, ErrChanged) {
+		t.Fatalf("Expected ErrChanged, got: %v", err)
+	}
+	// Nothing is moved back, not even the unchanged file
+	if _, err := os.Stat(filepath.Join(dir, "moved-a.md")); err != nil {
+		t.Errorf("moved-a.md was moved back: %v", err)
+	}
+
+	// The original path of moved-a.md is taken by another file
+	writeFile(t, filepath.Join(dir, "a.md"), "new a")
+	entries, err := j.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if err := check(entries[:1]); !errors.Is(err, ErrChanged) {
+		t.Errorf("Expected ErrChanged for a taken path, got: %v", err)
+	}
+}
+
+func TestUndo_Copy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	src := filepath.Join(dir, "a.md")
+	writeFile(t, src, "a")
+	j := Open(path, "ln")
+	if err := os.Symlink(src, filepath.Join(dir, "link.md")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	e, err := NewEntry(OpLink, src, filepath.Join(dir, "link.md"))
+	if err != nil {
+		t.Fatalf("NewEntry failed: %v", err)
+	}
+	if err := j.Append(e); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	if _, err := Open(path, "undo").Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "link.md")); !os.IsNotExist(err) {
+		t.Error("Symlink was not removed")
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("Original was removed: %v", err)
+	}
+}
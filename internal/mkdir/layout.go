@@ -0,0 +1,60 @@
+package mkdir
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultLayout is the layout of the date-based directory used when none is configured.
+const DefaultLayout = "2006-01-02"
+
+// placeholderPattern matches the placeholders of a layout such as {branch}.
+var placeholderPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// expandLayout returns the directory path, relative to the hiden directory, for layout at now.
+// layout is a Go time layout (e.g. "2006/01/02" or "2006-01") that may contain
+// the placeholders {date} (2006-01-02), {week} (ISO week such as 2025-W49)
+// and {branch} (the current git branch). branch is called only when needed.
+func expandLayout(layout string, now time.Time, branch func() string) (string, error) {
+	if layout == "" {
+		layout = DefaultLayout
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringSubmatchIndex(layout, -1) {
+		// Format the literal part with the Go time layout
+		b.WriteString(now.Format(layout[last:loc[0]]))
+		last = loc[1]
+
+		switch name := layout[loc[2]:loc[3]]; name {
+		case "date":
+			b.WriteString(now.Format("2006-01-02"))
+		case "week":
+			year, week := now.ISOWeek()
+			fmt.Fprintf(&b, "%d-W%02d", year, week)
+		case "branch":
+			b.WriteString(sanitizeBranch(branch()))
+		default:
+			return "", fmt.Errorf("unknown placeholder {%s} in layout %q", name, layout)
+		}
+	}
+	b.WriteString(now.Format(layout[last:]))
+
+	dir := filepath.Clean(b.String())
+	if filepath.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid layout %q: must expand to a relative path inside the hiden directory", layout)
+	}
+	return dir, nil
+}
+
+// sanitizeBranch turns a branch name into a single directory name.
+func sanitizeBranch(branch string) string {
+	if branch == "" {
+		return "detached"
+	}
+	return strings.ReplaceAll(branch, "/", "-")
+}
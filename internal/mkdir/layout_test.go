@@ -0,0 +1,58 @@
+package mkdir
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandLayout(t *testing.T) {
+	now := time.Date(2025, 12, 4, 15, 4, 5, 0, time.UTC)
+	branch := func() string { return "feature/login" }
+
+	tests := []struct {
+		layout   string
+		expected string
+	}{
+		{"", "2025-12-04"},
+		{"2006-01-02", "2025-12-04"},
+		{"2006/01/02", filepath.Join("2025", "12", "04")},
+		{"2006-01", "2025-12"},
+		{"{week}", "2025-W49"},
+		{"{branch}/{date}", filepath.Join("feature-login", "2025-12-04")},
+		{"2006/{week}", filepath.Join("2025", "2025-W49")},
+	}
+
+	for _, tt := range tests {
+		got, err := expandLayout(tt.layout, now, branch)
+		if err != nil {
+			t.Errorf("expandLayout(%q): unexpected error: %v", tt.layout, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("expandLayout(%q): expected %q, got %q", tt.layout, tt.expected, got)
+		}
+	}
+}
+
+func TestExpandLayout_DetachedHead(t *testing.T) {
+	now := time.Date(2025, 12, 4, 0, 0, 0, 0, time.UTC)
+	got, err := expandLayout("{branch}", now, func() string { return "" })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "detached" {
+		t.Errorf("Expected %q, got %q", "detached", got)
+	}
+}
+
+func TestExpandLayout_Invalid(t *testing.T) {
+	now := time.Date(2025, 12, 4, 0, 0, 0, 0, time.UTC)
+	branch := func() string { return "main" }
+
+	for _, layout := range []string{"{unknown}", "/2006", "../2006", "."} {
+		if _, err := expandLayout(layout, now, branch); err == nil {
+			t.Errorf("expandLayout(%q): expected error", layout)
+		}
+	}
+}
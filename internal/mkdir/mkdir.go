@@ -1,21 +1,21 @@
 package mkdir
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 	"time"
+
+	"github.com/qawatake/hiden/internal/journal"
+	"github.com/qawatake/hiden/internal/repo"
 )
 
-var ErrNotInGitRepo = errors.New("not in a git repository")
+var ErrNotInGitRepo = repo.ErrNotInGitRepo
 
 // Run creates a date-based directory in the hiden directory of the current git repository.
 // Returns the relative path from repository root.
-func Run(dirname string) (string, error) {
-	absPath, relPath, err := EnsureDir(dirname)
+func Run(dirname, layout string, j *journal.Journal) (string, error) {
+	absPath, relPath, err := EnsureDir(dirname, layout, j)
 	if err != nil {
 		return "", err
 	}
@@ -24,43 +24,78 @@ func Run(dirname string) (string, error) {
 }
 
 // EnsureDir creates a date-based directory in the hiden directory of the current git repository.
+// The directory is named after layout (see expandLayout); an empty layout means DefaultLayout.
+// The created directories are recorded in j unless j is nil.
 // Returns the absolute path and relative path from repository root.
-func EnsureDir(dirname string) (absPath string, relPath string, err error) {
-	// Get git repository root
-	repoRoot, err := getGitRepoRoot()
+func EnsureDir(dirname, layout string, j *journal.Journal) (absPath string, relPath string, err error) {
+	absPath, relPath, err = Resolve(dirname, layout)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Get current date in YYYY-MM-DD format
-	today := time.Now().Format("2006-01-02")
+	if err := Create(absPath, j); err != nil {
+		return "", "", err
+	}
 
-	// Construct the directory path
-	absPath = filepath.Join(repoRoot, dirname, today)
+	return absPath, relPath, nil
+}
 
-	// Create the directory (including parent directories if needed)
-	if err := os.MkdirAll(absPath, 0755); err != nil {
-		return "", "", fmt.Errorf("failed to create directory: %w", err)
+// Create creates the directory path along with any missing parents,
+// recording the created directories in j unless j is nil.
+func Create(path string, j *journal.Journal) error {
+	// Find the missing directories, outermost first
+	var missing []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		missing = append([]string{dir}, missing...)
 	}
 
-	// Return relative path from repository root
-	relPath = filepath.Join(dirname, today)
+	// Create the directory (including parent directories if needed)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
 
-	return absPath, relPath, nil
+	if j == nil {
+		return nil
+	}
+	var records []journal.Entry
+	for _, dir := range missing {
+		e, err := journal.NewEntry(journal.OpMkdir, "", dir)
+		if err != nil {
+			return err
+		}
+		records = append(records, e)
+	}
+	if err := j.Append(records...); err != nil {
+		return fmt.Errorf("failed to record directories: %w", err)
+	}
+	return nil
 }
 
-// getGitRepoRoot returns the root directory of the git repository
-func getGitRepoRoot() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	output, err := cmd.Output()
+// Resolve returns the absolute path and relative path from repository root of
+// the date-based directory like EnsureDir, without creating it.
+func Resolve(dirname, layout string) (absPath string, relPath string, err error) {
+	// Get git repository root
+	repoRoot, err := repo.Root()
 	if err != nil {
-		// Check if it's because we're not in a git repo
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 128 {
-			return "", ErrNotInGitRepo
-		}
-		return "", fmt.Errorf("failed to get git repository root: %w", err)
+		return "", "", err
+	}
+
+	// Expand the layout for the current date
+	today, err := expandLayout(layout, time.Now(), func() string {
+		return repo.Branch(repoRoot)
+	})
+	if err != nil {
+		return "", "", err
 	}
 
-	repoRoot := strings.TrimSpace(string(output))
-	return repoRoot, nil
+	// Construct the directory path
+	absPath = filepath.Join(repoRoot, dirname, today)
+
+	// Return relative path from repository root
+	relPath = filepath.Join(dirname, today)
+
+	return absPath, relPath, nil
 }
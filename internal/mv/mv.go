@@ -1,43 +1,401 @@
 package mv
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/qawatake/hiden/internal/fsutil"
+	"github.com/qawatake/hiden/internal/journal"
 	"github.com/qawatake/hiden/internal/mkdir"
+	"github.com/qawatake/hiden/internal/repo"
 )
 
-// Run moves files to the date-based hiden directory in the current git repository.
-// It creates the directory if it doesn't exist.
-func Run(dirname string, filePaths []string) ([]string, error) {
+// ErrExists is returned when a destination already exists and Options.Conflict is ConflictRefuse.
+var ErrExists = errors.New("destination already exists")
+
+// Conflict decides what happens when a destination already exists.
+type Conflict int
+
+const (
+	// ConflictRefuse fails before moving anything. It is the default.
+	ConflictRefuse Conflict = iota
+	// ConflictSkip leaves the source in place.
+	ConflictSkip
+	// ConflictBackup renames the existing destination to a numbered backup (name.~N~).
+	ConflictBackup
+	// ConflictOverwrite replaces the existing destination.
+	ConflictOverwrite
+	// ConflictPrompt asks Options.Confirm whether to replace the existing destination.
+	ConflictPrompt
+)
+
+// Action is what Run does with each file.
+type Action int
+
+const (
+	// ActionMove moves the file. It is the default.
+	ActionMove Action = iota
+	// ActionCopy copies the file recursively, preserving permissions and modification times.
+	ActionCopy
+	// ActionLink creates a symlink to the absolute path of the file.
+	ActionLink
+)
+
+// verb returns the verb used in error messages.
+func (a Action) verb() string {
+	switch a {
+	case ActionCopy:
+		return "copy"
+	case ActionLink:
+		return "link"
+	}
+	return "move"
+}
+
+// op returns the journal operation of the action.
+func (a Action) op() string {
+	switch a {
+	case ActionCopy:
+		return journal.OpCopy
+	case ActionLink:
+		return journal.OpLink
+	}
+	return journal.OpMove
+}
+
+// Options configures Run.
+type Options struct {
+	// Layout is the layout of the date-based directory (see mkdir.EnsureDir).
+	Layout string
+	// Action is what is done with each file: moving, copying or linking it.
+	Action Action
+	// Conflict decides what happens when a destination already exists.
+	Conflict Conflict
+	// Confirm is asked whether to overwrite dst with ConflictPrompt.
+	// A nil Confirm skips every conflicting file.
+	Confirm func(dst string) bool
+	// Parents keeps the path of each file relative to the repository root
+	// under the date-based directory instead of only its base name.
+	Parents bool
+	// Atomic checks every source before moving anything and, if a move fails,
+	// moves the already moved files back. The error is then a *RollbackError.
+	Atomic bool
+	// Journal records the moved files and created directories so that they
+	// can be restored or undone. A nil Journal records nothing.
+	Journal *journal.Journal
+}
+
+// RollbackError is returned by Run with Options.Atomic when a move fails.
+type RollbackError struct {
+	// Err is the error that caused the rollback.
+	Err error
+	// Restored lists the original paths of the files moved back.
+	Restored []string
+	// Unrestored holds the errors of the files that could not be moved back.
+	Unrestored []error
+}
+
+func (e *RollbackError) Error() string {
+	msg := fmt.Sprintf("%v; rolled back %d file(s)", e.Err, len(e.Restored))
+	for _, err := range e.Unrestored {
+		msg += fmt.Sprintf("; %v", err)
+	}
+	return msg
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// rename is a rename done by Run, recorded to be undone on rollback.
+// An empty from means that to was created and is removed on rollback.
+type rename struct {
+	from, to string
+}
+
+// Move is a planned move of a file.
+type Move struct {
+	// Src is the path of the file to move as given.
+	Src string
+	// Dst is the absolute destination path.
+	Dst string
+	// Rel is the destination path relative to the repository root.
+	Rel string
+	// Exists reports that Dst already exists, or is the destination of an earlier move.
+	Exists bool
+	// Skip reports that the file is not moved because of ConflictSkip.
+	Skip bool
+	// Backup is the path the existing destination is renamed to with ConflictBackup.
+	Backup string
+}
+
+// Plan returns the moves Run would perform, without touching the file system.
+// It fails with ErrExists on the first conflict when opts.Conflict is ConflictRefuse.
+func Plan(dirname string, filePaths []string, opts Options) ([]Move, error) {
 	if len(filePaths) == 0 {
 		return nil, fmt.Errorf("no files specified")
 	}
 
-	// Ensure the target directory exists
-	targetDir, relDir, err := mkdir.EnsureDir(dirname)
+	targetDir, relDir, err := mkdir.Resolve(dirname, opts.Layout)
 	if err != nil {
 		return nil, err
 	}
 
-	var relPaths []string
+	var repoRoot string
+	if opts.Parents {
+		if repoRoot, err = repo.Root(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Paths that will exist once the earlier moves are done
+	planned := make(map[string]bool)
+	exists := func(path string) bool {
+		if planned[path] {
+			return true
+		}
+		_, err := os.Lstat(path)
+		return err == nil
+	}
+
+	var moves []Move
 	for _, filePath := range filePaths {
-		// Get the base name of the file
-		baseName := filepath.Base(filePath)
+		// Get the base name of the file, or its path from the repository root
+		name := filepath.Base(filePath)
+		if opts.Parents {
+			if name, err = pathInRepo(repoRoot, filePath); err != nil {
+				return nil, fmt.Errorf("failed to %s file %s: %w", opts.Action.verb(), filePath, err)
+			}
+		}
 
-		// Construct the target file path
-		targetPath := filepath.Join(targetDir, baseName)
+		m := Move{
+			Src: filePath,
+			Dst: filepath.Join(targetDir, name),
+			Rel: filepath.Join(relDir, name),
+		}
+
+		if exists(m.Dst) {
+			m.Exists = true
+			switch opts.Conflict {
+			case ConflictRefuse:
+				return nil, fmt.Errorf("failed to %s file %s: %w: %s", opts.Action.verb(), filePath, ErrExists, m.Rel)
+			case ConflictSkip:
+				m.Skip = true
+			case ConflictBackup:
+				m.Backup = backupPath(m.Dst, exists)
+				planned[m.Backup] = true
+			}
+		}
 
-		// Move the file
-		if err := os.Rename(filePath, targetPath); err != nil {
-			return relPaths, fmt.Errorf("failed to move file %s: %w", filePath, err)
+		if !m.Skip {
+			planned[m.Dst] = true
+		}
+		moves = append(moves, m)
+	}
+
+	return moves, nil
+}
+
+// pathInRepo returns the path of filePath relative to repoRoot.
+func pathInRepo(repoRoot, filePath string) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	// Resolve symlinks in the parent directories only, since git reports the
+	// resolved repository root, but keep the file itself as given
+	parent, err := filepath.EvalSymlinks(filepath.Dir(absPath))
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(repoRoot, filepath.Join(parent, filepath.Base(absPath)))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("not inside the repository %s", repoRoot)
+	}
+	return rel, nil
+}
+
+// backupPath returns the first numbered backup path of path (path.~N~) that does not exist.
+func backupPath(path string, exists func(string) bool) string {
+	for n := 1; ; n++ {
+		backup := fmt.Sprintf("%s.~%d~", path, n)
+		if !exists(backup) {
+			return backup
+		}
+	}
+}
+
+// Run moves, copies or links files or directories to the date-based hiden
+// directory in the current git repository, depending on opts.Action.
+// It creates the directory if it doesn't exist.
+// Returns the paths relative to the repository root of the moved files.
+func Run(dirname string, filePaths []string, opts Options) ([]string, error) {
+	moves, err := Plan(dirname, filePaths, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Atomic {
+		for _, m := range moves {
+			if _, err := os.Lstat(m.Src); err != nil && !m.Skip {
+				return nil, fmt.Errorf("failed to %s file %s: %w", opts.Action.verb(), m.Src, err)
+			}
+		}
+	}
+
+	// Ensure the target directory exists
+	if _, _, err := mkdir.EnsureDir(dirname, opts.Layout, opts.Journal); err != nil {
+		return nil, err
+	}
+
+	var (
+		relPaths []string
+		done     []rename
+		// overwritten holds files set aside instead of being overwritten in atomic mode
+		overwritten []string
+		// aside is the directory set aside to be overwritten by the current move
+		// outside atomic mode, restored if the move fails.
+		aside   string
+		records []journal.Entry
+	)
+	record := func() error {
+		if opts.Journal == nil {
+			return nil
+		}
+		if err := opts.Journal.Append(records...); err != nil {
+			return fmt.Errorf("failed to record moves: %w", err)
+		}
+		return nil
+	}
+	fail := func(err error) ([]string, error) {
+		if !opts.Atomic {
+			if aside != "" {
+				if rerr := os.Rename(aside, strings.TrimSuffix(aside, asideSuffix)); rerr != nil {
+					err = errors.Join(err, fmt.Errorf("failed to restore overwritten file: %w", rerr))
+				}
+			}
+			// Keep the files moved so far restorable
+			return relPaths, errors.Join(err, record())
+		}
+		return nil, rollback(done, err)
+	}
+
+	for _, m := range moves {
+		if m.Skip {
+			continue
+		}
+		if m.Exists && opts.Conflict == ConflictPrompt && (opts.Confirm == nil || !opts.Confirm(m.Dst)) {
+			continue
+		}
+
+		if m.Backup != "" {
+			if err := os.Rename(m.Dst, m.Backup); err != nil {
+				return fail(fmt.Errorf("failed to back up %s: %w", m.Rel, err))
+			}
+			done = append(done, rename{from: m.Dst, to: m.Backup})
+			if e, err := journal.NewEntry(journal.OpBackup, m.Dst, m.Backup); err == nil {
+				records = append(records, e)
+			}
+		} else if m.Exists && (opts.Atomic || isDir(m.Dst)) {
+			// Keep the overwritten file until every move succeeds. A directory
+			// is set aside in any case, since a rename cannot replace it
+			path := m.Dst + asideSuffix
+			if err := os.Rename(m.Dst, path); err != nil {
+				return fail(fmt.Errorf("failed to set aside %s: %w", m.Rel, err))
+			}
+			if opts.Atomic {
+				done = append(done, rename{from: m.Dst, to: path})
+				overwritten = append(overwritten, path)
+			} else {
+				aside = path
+			}
+		}
+
+		if opts.Parents {
+			if err := mkdir.Create(filepath.Dir(m.Dst), opts.Journal); err != nil {
+				return fail(err)
+			}
+		}
+
+		absSrc, err := filepath.Abs(m.Src)
+		if err != nil {
+			return fail(fmt.Errorf("failed to %s file %s: %w", opts.Action.verb(), m.Src, err))
+		}
+
+		switch opts.Action {
+		case ActionCopy:
+			err = fsutil.Copy(m.Src, m.Dst)
+		case ActionLink:
+			if _, err = os.Lstat(m.Src); err == nil {
+				err = fsutil.Symlink(absSrc, m.Dst)
+			}
+		default:
+			// Move the file, copying it when the hiden directory is on another file system
+			err = fsutil.Move(m.Src, m.Dst)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("failed to %s file %s: %w", opts.Action.verb(), m.Src, err))
+		}
+		if opts.Action == ActionMove {
+			done = append(done, rename{from: m.Src, to: m.Dst})
+		} else {
+			done = append(done, rename{to: m.Dst})
+		}
+		if e, err := journal.NewEntry(opts.Action.op(), absSrc, m.Dst); err == nil {
+			records = append(records, e)
 		}
 
 		// Collect path relative to repository root
-		relPath := filepath.Join(relDir, baseName)
-		relPaths = append(relPaths, relPath)
+		relPaths = append(relPaths, m.Rel)
+
+		if aside != "" {
+			path := aside
+			aside = ""
+			if err := os.RemoveAll(path); err != nil {
+				return relPaths, errors.Join(fmt.Errorf("failed to remove overwritten file: %w", err), record())
+			}
+		}
+	}
+
+	for _, path := range overwritten {
+		if err := os.RemoveAll(path); err != nil {
+			return relPaths, errors.Join(fmt.Errorf("failed to remove overwritten file: %w", err), record())
+		}
 	}
 
-	return relPaths, nil
+	return relPaths, record()
+}
+
+// asideSuffix is appended to the name of a file set aside until it is overwritten.
+const asideSuffix = ".~hiden-rollback~"
+
+// isDir reports whether path is a directory, without following symlinks.
+func isDir(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
+
+// rollback undoes the renames in reverse order and returns a *RollbackError for cause.
+func rollback(done []rename, cause error) error {
+	rbErr := &RollbackError{Err: cause}
+	for i := len(done) - 1; i >= 0; i-- {
+		r := done[i]
+		if r.from == "" {
+			if err := os.RemoveAll(r.to); err != nil {
+				rbErr.Unrestored = append(rbErr.Unrestored, fmt.Errorf("failed to remove %s: %w", r.to, err))
+			}
+			continue
+		}
+		if err := fsutil.Move(r.to, r.from); err != nil {
+			rbErr.Unrestored = append(rbErr.Unrestored, fmt.Errorf("failed to restore %s from %s: %w", r.from, r.to, err))
+			continue
+		}
+		rbErr.Restored = append(rbErr.Restored, r.from)
+	}
+	return rbErr
 }
@@ -1,6 +1,7 @@
 package mv
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -8,7 +9,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/qawatake/hiden/internal/journal"
 	"github.com/qawatake/hiden/internal/mkdir"
+	"github.com/qawatake/hiden/internal/repo"
 )
 
 func TestRun_Success(t *testing.T) {
@@ -40,7 +43,7 @@ func TestRun_Success(t *testing.T) {
 	}
 
 	// Run the mv command
-	result, err := Run(".hiden", []string{testFile})
+	result, err := Run(".hiden", []string{testFile}, Options{})
 	if err != nil {
 		t.Fatalf("Run failed: %v", err)
 	}
@@ -103,7 +106,7 @@ func TestRun_MultipleFiles(t *testing.T) {
 		t.Fatalf("Failed to change to temp dir: %v", err)
 	}
 
-	result, err := Run(".hiden", filePaths)
+	result, err := Run(".hiden", filePaths, Options{})
 	if err != nil {
 		t.Fatalf("Run failed: %v", err)
 	}
@@ -158,7 +161,7 @@ func TestRun_NotInGitRepo(t *testing.T) {
 	}
 
 	// Run the mv command
-	_, err = Run(".hiden", []string{testFile})
+	_, err = Run(".hiden", []string{testFile}, Options{})
 	if err == nil {
 		t.Fatal("Expected error when not in git repo")
 	}
@@ -190,7 +193,7 @@ func TestRun_FileNotExist(t *testing.T) {
 	}
 
 	// Try to move a non-existent file
-	_, err = Run(".hiden", []string{filepath.Join(tmpDir, "nonexistent.txt")})
+	_, err = Run(".hiden", []string{filepath.Join(tmpDir, "nonexistent.txt")}, Options{})
 	if err == nil {
 		t.Fatal("Expected error when file does not exist")
 	}
@@ -235,7 +238,7 @@ func TestRun_CreatesDirectory(t *testing.T) {
 	}
 
 	// Run the mv command
-	_, err = Run(".hiden", []string{testFile})
+	_, err = Run(".hiden", []string{testFile}, Options{})
 	if err != nil {
 		t.Fatalf("Run failed: %v", err)
 	}
@@ -272,7 +275,7 @@ func TestRun_PartialFailure(t *testing.T) {
 		t.Fatalf("Failed to change to temp dir: %v", err)
 	}
 
-	result, err := Run(".hiden", []string{existingFile, nonExistentFile})
+	result, err := Run(".hiden", []string{existingFile, nonExistentFile}, Options{})
 	if err == nil {
 		t.Fatal("Expected error for non-existent file")
 	}
@@ -290,3 +293,486 @@ func TestRun_PartialFailure(t *testing.T) {
 		t.Errorf("Expected %q, got %q", expectedRelPath, result[0])
 	}
 }
+
+// setupGitRepo creates a git repository in a temporary directory and changes to it.
+func setupGitRepo(t *testing.T) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	cmd := exec.Command("git", "init")
+	cmd.Dir = tmpDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init git repo: %v", err)
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current dir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(originalDir) })
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change to temp dir: %v", err)
+	}
+
+	return tmpDir
+}
+
+// setupConflict creates a file named name with content "new" in dir and an
+// existing file with content "old" at its destination in today's hiden directory.
+func setupConflict(t *testing.T, dir, name string) (src, dst string) {
+	t.Helper()
+
+	today := time.Now().Format("2006-01-02")
+	dst = filepath.Join(dir, ".hiden", today, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("Failed to create hiden dir: %v", err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	src = filepath.Join(dir, name)
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	return src, dst
+}
+
+func assertContent(t *testing.T, path, expected string) {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if string(content) != expected {
+		t.Errorf("%s: expected %q, got %q", path, expected, string(content))
+	}
+}
+
+func TestRun_ConflictRefuse(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	src, dst := setupConflict(t, tmpDir, "notes.md")
+
+	other := filepath.Join(tmpDir, "other.md")
+	if err := os.WriteFile(other, []byte("other"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	_, err := Run(".hiden", []string{other, src}, Options{})
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists, got: %v", err)
+	}
+
+	// Nothing is moved, not even the non-conflicting file
+	assertContent(t, dst, "old")
+	assertContent(t, src, "new")
+	assertContent(t, other, "other")
+}
+
+func TestRun_ConflictRefuseWithinBatch(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+
+	var filePaths []string
+	for _, dir := range []string{"a", "b"} {
+		p := filepath.Join(tmpDir, dir, "notes.md")
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(dir), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		filePaths = append(filePaths, p)
+	}
+
+	if _, err := Run(".hiden", filePaths, Options{}); !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists, got: %v", err)
+	}
+	assertContent(t, filePaths[0], "a")
+}
+
+func TestRun_ConflictSkip(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	src, dst := setupConflict(t, tmpDir, "notes.md")
+
+	result, err := Run(".hiden", []string{src}, Options{Conflict: ConflictSkip})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no moved files, got %v", result)
+	}
+	assertContent(t, dst, "old")
+	assertContent(t, src, "new")
+}
+
+func TestRun_ConflictBackup(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	src, dst := setupConflict(t, tmpDir, "notes.md")
+	if err := os.WriteFile(dst+".~1~", []byte("older"), 0644); err != nil {
+		t.Fatalf("Failed to create backup file: %v", err)
+	}
+
+	if _, err := Run(".hiden", []string{src}, Options{Conflict: ConflictBackup}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	assertContent(t, dst, "new")
+	assertContent(t, dst+".~1~", "older")
+	assertContent(t, dst+".~2~", "old")
+}
+
+func TestRun_ConflictOverwrite(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	src, dst := setupConflict(t, tmpDir, "notes.md")
+
+	if _, err := Run(".hiden", []string{src}, Options{Conflict: ConflictOverwrite}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	assertContent(t, dst, "new")
+}
+
+func TestRun_ConflictPrompt(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	srcA, dstA := setupConflict(t, tmpDir, "a.md")
+	srcB, dstB := setupConflict(t, tmpDir, "b.md")
+
+	var asked []string
+	opts := Options{
+		Conflict: ConflictPrompt,
+		Confirm: func(dst string) bool {
+			asked = append(asked, filepath.Base(dst))
+			return filepath.Base(dst) == "a.md"
+		},
+	}
+	if _, err := Run(".hiden", []string{srcA, srcB}, opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(asked) != 2 {
+		t.Errorf("Expected 2 prompts, got %v", asked)
+	}
+	assertContent(t, dstA, "new")
+	assertContent(t, dstB, "old")
+	assertContent(t, srcB, "new")
+}
+
+func TestPlan_DoesNotTouchFiles(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	src, _ := setupConflict(t, tmpDir, "notes.md")
+	fresh := filepath.Join(tmpDir, "fresh.md")
+	if err := os.WriteFile(fresh, []byte("fresh"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	moves, err := Plan(".hiden", []string{src, fresh}, Options{Conflict: ConflictBackup})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	if len(moves) != 2 {
+		t.Fatalf("Expected 2 moves, got %d", len(moves))
+	}
+	if !moves[0].Exists || filepath.Base(moves[0].Backup) != "notes.md.~1~" {
+		t.Errorf("Expected backup for notes.md, got %+v", moves[0])
+	}
+	if moves[1].Exists || moves[1].Backup != "" {
+		t.Errorf("Expected plain move for fresh.md, got %+v", moves[1])
+	}
+
+	assertContent(t, src, "new")
+	assertContent(t, fresh, "fresh")
+}
+
+func TestRun_Directory(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+
+	srcDir := filepath.Join(tmpDir, "scratch")
+	if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "sub", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := Run(".hiden", []string{srcDir + string(filepath.Separator)}, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	expectedRelPath := filepath.Join(".hiden", today, "scratch")
+	if len(result) != 1 || result[0] != expectedRelPath {
+		t.Errorf("Expected result %q, got %q", []string{expectedRelPath}, result)
+	}
+	assertContent(t, filepath.Join(tmpDir, expectedRelPath, "sub", "a.txt"), "a")
+	if _, err := os.Stat(srcDir); !os.IsNotExist(err) {
+		t.Error("Original directory still exists after move")
+	}
+}
+
+func TestRun_OverwriteDirectory(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"move", Options{Conflict: ConflictOverwrite}},
+		{"copy", Options{Action: ActionCopy, Conflict: ConflictOverwrite}},
+		{"copy confirmed", Options{Action: ActionCopy, Conflict: ConflictPrompt, Confirm: func(string) bool { return true }}},
+		{"atomic", Options{Conflict: ConflictOverwrite, Atomic: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := setupGitRepo(t)
+			if err := os.Mkdir(filepath.Join(tmpDir, "d"), 0755); err != nil {
+				t.Fatalf("Failed to create dir: %v", err)
+			}
+			src, dst := setupConflict(t, tmpDir, filepath.Join("d", "new.txt"))
+			if err := os.WriteFile(filepath.Join(filepath.Dir(dst), "old.txt"), []byte("old"), 0644); err != nil {
+				t.Fatalf("Failed to create existing file: %v", err)
+			}
+			src, dst = filepath.Dir(src), filepath.Dir(dst)
+
+			if _, err := Run(".hiden", []string{src}, tt.opts); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			assertContent(t, filepath.Join(dst, "new.txt"), "new")
+			if _, err := os.Stat(filepath.Join(dst, "old.txt")); !os.IsNotExist(err) {
+				t.Error("Expected the existing directory to be replaced")
+			}
+			if _, err := os.Stat(dst + asideSuffix); !os.IsNotExist(err) {
+				t.Error("Expected the existing directory to be removed")
+			}
+		})
+	}
+}
+
+func TestRun_AtomicValidatesSources(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+
+	existingFile := filepath.Join(tmpDir, "exists.txt")
+	if err := os.WriteFile(existingFile, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	nonExistentFile := filepath.Join(tmpDir, "nope.txt")
+
+	result, err := Run(".hiden", []string{existingFile, nonExistentFile}, Options{Atomic: true})
+	if err == nil {
+		t.Fatal("Expected error for non-existent file")
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no moved files, got %v", result)
+	}
+	assertContent(t, existingFile, "content")
+}
+
+func TestRun_AtomicRollback(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+
+	srcA := filepath.Join(tmpDir, "a.md")
+	if err := os.WriteFile(srcA, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	srcB, dstB := setupConflict(t, tmpDir, "b.md")
+
+	// Removing b.md while confirming makes its move fail after a.md was moved
+	opts := Options{
+		Conflict: ConflictPrompt,
+		Atomic:   true,
+		Confirm: func(dst string) bool {
+			os.Remove(srcB)
+			return true
+		},
+	}
+	result, err := Run(".hiden", []string{srcA, srcB}, opts)
+
+	var rbErr *RollbackError
+	if !errors.As(err, &rbErr) {
+		t.Fatalf("Expected RollbackError, got: %v", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no moved files, got %v", result)
+	}
+	if len(rbErr.Restored) != 2 || len(rbErr.Unrestored) != 0 {
+		t.Errorf("Expected 2 restored files, got %v (unrestored: %v)", rbErr.Restored, rbErr.Unrestored)
+	}
+
+	// a.md is back, and the file b.md would have overwritten is intact
+	assertContent(t, srcA, "a")
+	assertContent(t, dstB, "old")
+	entries, err := os.ReadDir(filepath.Dir(dstB))
+	if err != nil {
+		t.Fatalf("Failed to read hiden dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only b.md in hiden dir, got %d entries", len(entries))
+	}
+}
+
+func TestRun_AtomicOverwrite(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	src, dst := setupConflict(t, tmpDir, "notes.md")
+
+	if _, err := Run(".hiden", []string{src}, Options{Conflict: ConflictOverwrite, Atomic: true}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	assertContent(t, dst, "new")
+	if _, err := os.Stat(dst + ".~hiden-rollback~"); !os.IsNotExist(err) {
+		t.Error("Overwritten file was not removed")
+	}
+}
+
+func TestRun_Parents(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+
+	// Same base name in different directories, one given relative to the current directory
+	var filePaths []string
+	for _, dir := range []string{"a", filepath.Join("b", "c")} {
+		p := filepath.Join(dir, "notes.md")
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, p), []byte(dir), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		filePaths = append(filePaths, p)
+	}
+	filePaths[1] = filepath.Join(tmpDir, filePaths[1])
+
+	result, err := Run(".hiden", filePaths, Options{Parents: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	expected := []string{
+		filepath.Join(".hiden", today, "a", "notes.md"),
+		filepath.Join(".hiden", today, "b", "c", "notes.md"),
+	}
+	if strings.Join(result, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected result %q, got %q", expected, result)
+	}
+	assertContent(t, filepath.Join(tmpDir, expected[0]), "a")
+	assertContent(t, filepath.Join(tmpDir, expected[1]), filepath.Join("b", "c"))
+}
+
+func TestRun_ParentsOutsideRepo(t *testing.T) {
+	setupGitRepo(t)
+
+	outside := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(outside, []byte("outside"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	_, err := Run(".hiden", []string{outside}, Options{Parents: true})
+	if err == nil || !strings.Contains(err.Error(), "not inside the repository") {
+		t.Fatalf("Expected error for file outside the repository, got: %v", err)
+	}
+	assertContent(t, outside, "outside")
+}
+
+func TestRun_Journal(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+
+	testFile := filepath.Join(tmpDir, "notes.md")
+	if err := os.WriteFile(testFile, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	j := journal.Open(filepath.Join(t.TempDir(), "journal.jsonl"), "test")
+	result, err := Run(".hiden", []string{"notes.md"}, Options{Journal: j})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	root, err := repo.Root()
+	if err != nil {
+		t.Fatalf("Failed to get repository root: %v", err)
+	}
+	src, ok, err := j.Origin(filepath.Join(root, result[0]))
+	if err != nil {
+		t.Fatalf("Origin failed: %v", err)
+	}
+	if !ok || filepath.Base(src) != "notes.md" || !filepath.IsAbs(src) {
+		t.Errorf("Expected absolute origin of notes.md, got %q (found: %v)", src, ok)
+	}
+}
+
+func TestRun_Copy(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+
+	dir := filepath.Join(tmpDir, "scripts")
+	if err := os.MkdirAll(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	script := filepath.Join(dir, "lib", "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := Run(".hiden", []string{dir}, Options{Action: ActionCopy})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	copied := filepath.Join(tmpDir, result[0], "lib", "run.sh")
+	assertContent(t, copied, "#!/bin/sh\n")
+	info, err := os.Stat(copied)
+	if err != nil {
+		t.Fatalf("Failed to stat copy: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %v", info.Mode().Perm())
+	}
+	// The original is left in place
+	assertContent(t, script, "#!/bin/sh\n")
+}
+
+func TestRun_Link(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	src, dst := setupConflict(t, tmpDir, "notes.md")
+
+	if _, err := Run(".hiden", []string{"notes.md"}, Options{Action: ActionLink, Conflict: ConflictBackup}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	target, err := os.Readlink(dst)
+	if err != nil {
+		t.Fatalf("Expected a symlink at %s: %v", dst, err)
+	}
+	if !filepath.IsAbs(target) || filepath.Base(target) != "notes.md" {
+		t.Errorf("Expected an absolute link to notes.md, got %s", target)
+	}
+	assertContent(t, dst, "new")
+	assertContent(t, dst+".~1~", "old")
+	assertContent(t, src, "new")
+}
+
+func TestRun_AtomicCopyRollback(t *testing.T) {
+	tmpDir := setupGitRepo(t)
+	srcA, _ := setupConflict(t, tmpDir, "a.md")
+	srcB := filepath.Join(tmpDir, "b.md")
+	if err := os.WriteFile(srcB, []byte("b"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Removing b.md while confirming a.md makes copying b.md fail
+	confirm := func(string) bool {
+		os.Remove(srcB)
+		return true
+	}
+	_, err := Run(".hiden", []string{srcA, srcB}, Options{Action: ActionCopy, Conflict: ConflictPrompt, Confirm: confirm, Atomic: true})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	// Neither copy is left behind and the existing a.md is back
+	today := time.Now().Format("2006-01-02")
+	assertContent(t, filepath.Join(tmpDir, ".hiden", today, "a.md"), "old")
+	if _, err := os.Lstat(filepath.Join(tmpDir, ".hiden", today, "b.md")); !os.IsNotExist(err) {
+		t.Error("Copy of b.md was not removed")
+	}
+	assertContent(t, srcA, "new")
+}
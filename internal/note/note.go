@@ -0,0 +1,252 @@
+package note
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/qawatake/hiden/internal/mkdir"
+	"github.com/qawatake/hiden/internal/repo"
+)
+
+// defaultExt is the extension of notes created without one.
+const defaultExt = ".md"
+
+// Options configures New.
+type Options struct {
+	// Name is the file name of the note, or a title to derive it from.
+	// An empty name yields a timestamp-based file name.
+	Name string
+	// Template is the name of a template in TemplatesDir to expand into the note.
+	Template string
+	// TemplatesDir is the directory holding templates.
+	TemplatesDir string
+	// Layout is the layout of the date-based directory (see mkdir.EnsureDir).
+	Layout string
+}
+
+// New creates a note in the date-based hiden directory of the current git repository.
+// Returns the absolute path of the note.
+func New(dirname string, opts Options) (string, error) {
+	now := time.Now()
+
+	var (
+		tmplPath string
+		tmplText []byte
+		perm     os.FileMode = 0644
+	)
+	ext := defaultExt
+	if opts.Template != "" {
+		var err error
+		tmplPath, err = findTemplate(opts.TemplatesDir, opts.Template)
+		if err != nil {
+			return "", err
+		}
+		tmplText, err = os.ReadFile(tmplPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read template: %w", err)
+		}
+		if e := filepath.Ext(tmplPath); e != "" {
+			ext = e
+		}
+		if isScript(tmplPath, tmplText) {
+			perm = 0755
+		}
+	}
+
+	name, stamped, err := fileName(opts.Name, ext, now)
+	if err != nil {
+		return "", err
+	}
+
+	var repoRoot string
+	if tmplPath != "" {
+		repoRoot, err = repo.Root()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	targetDir, _, err := mkdir.EnsureDir(dirname, opts.Layout, nil)
+	if err != nil {
+		return "", err
+	}
+
+	f, path, err := create(targetDir, name, stamped, perm)
+	if err != nil {
+		return "", err
+	}
+
+	var content []byte
+	if tmplPath != "" {
+		content, err = render(filepath.Base(tmplPath), tmplText, newVars(repoRoot, title(opts.Name), filepath.Base(path), now))
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+			return "", err
+		}
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+
+	return path, nil
+}
+
+// create creates the file name in dir, failing if it exists, and returns it
+// with its path. A timestamp-based name is numbered instead, e.g. 150405-2.md,
+// since several notes may be created within the same second.
+func create(dir, name string, stamped bool, perm os.FileMode) (*os.File, string, error) {
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	ext := filepath.Ext(name)
+	for n := 2; errors.Is(err, os.ErrExist) && stamped; n++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext))
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, "", fmt.Errorf("note already exists: %s", path)
+		}
+		return nil, "", fmt.Errorf("failed to create note: %w", err)
+	}
+	return f, path, nil
+}
+
+// isScript reports whether a template creates an executable note:
+// the template file is executable or starts with a shebang.
+func isScript(path string, content []byte) bool {
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0111 != 0 {
+		return true
+	}
+	return bytes.HasPrefix(content, []byte("#!"))
+}
+
+// isFileName reports whether name is a file name rather than a title.
+func isFileName(name string) bool {
+	return filepath.Ext(name) != "" && !strings.ContainsRune(name, ' ')
+}
+
+// titleExt returns the extension ending the title, as in "My notes.md", or
+// empty if there is none. Only letters and digits, with at least one letter,
+// make an extension, so that "Meeting 2025.12" has none.
+func titleExt(title string) string {
+	ext := filepath.Ext(title)
+	if !strings.ContainsFunc(ext, unicode.IsLetter) {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return ""
+		}
+	}
+	return ext
+}
+
+// title returns the title given as the name of a note, without its
+// extension, or empty if name is a file name.
+func title(name string) string {
+	name = strings.TrimSpace(name)
+	if isFileName(name) {
+		return ""
+	}
+	return strings.TrimSuffix(name, titleExt(name))
+}
+
+// fileName returns the file name of a note, and whether it is based on the
+// timestamp now. A name with an extension is used as is; otherwise it is
+// treated as a title and turned into a slug, keeping the extension ending the
+// title if any. An empty name or slug falls back to a timestamp.
+func fileName(name, ext string, now time.Time) (string, bool, error) {
+	name = strings.TrimSpace(name)
+	if strings.ContainsAny(name, `/\`) {
+		return "", false, fmt.Errorf("invalid note name %q: must not contain path separators", name)
+	}
+
+	if isFileName(name) {
+		return name, false, nil
+	}
+	if e := titleExt(name); e != "" {
+		name, ext = strings.TrimSuffix(name, e), e
+	}
+
+	if slug := slugify(name); slug != "" {
+		return slug + ext, false, nil
+	}
+	return now.Format("150405") + ext, true, nil
+}
+
+// slugify lower-cases title and joins its words with hyphens.
+func slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// findTemplate returns the path of the template named name in dir.
+// name may omit the extension of the template file.
+func findTemplate(dir, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return path, nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, name+".*"))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			return match, nil
+		}
+	}
+
+	return "", fmt.Errorf("template %q not found in %s", name, dir)
+}
+
+// Edit opens path in $VISUAL or $EDITOR. It does nothing if neither is set.
+func Edit(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		return nil
+	}
+
+	// Run through the shell so that editors with arguments such as "code -w" work
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+
+	// Attach the editor to the terminal even when stdout is captured
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run editor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package note
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileName(t *testing.T) {
+	now := time.Date(2025, 12, 4, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		ext      string
+		expected string
+		stamped  bool
+	}{
+		{"", ".md", "150405.md", true},
+		{"notes.txt", ".md", "notes.txt", false},
+		{"Retro Notes", ".md", "retro-notes.md", false},
+		{"  Incident: DB down!  ", ".md", "incident-db-down.md", false},
+		{"deploy script", ".sh", "deploy-script.sh", false},
+		{"!!!", ".md", "150405.md", true},
+		// A title keeps its extension
+		{"My notes.md", ".md", "my-notes.md", false},
+		{"check db.sh", ".md", "check-db.sh", false},
+		{"Meeting 2025.12", ".md", "meeting-2025-12.md", false},
+		{"v1.2 release", ".md", "v1-2-release.md", false},
+	}
+
+	for _, tt := range tests {
+		got, stamped, err := fileName(tt.name, tt.ext, now)
+		if err != nil {
+			t.Errorf("fileName(%q): unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.expected || stamped != tt.stamped {
+			t.Errorf("fileName(%q): expected %q (stamped %v), got %q (stamped %v)", tt.name, tt.expected, tt.stamped, got, stamped)
+		}
+	}
+
+	if _, _, err := fileName("../escape.md", ".md", now); err == nil {
+		t.Error("Expected error for name with path separator")
+	}
+}
+
+func TestNew_WithTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := exec.Command("git", "init")
+	cmd.Dir = tmpDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init git repo: %v", err)
+	}
+
+	templatesDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templatesDir, "retro.md"), []byte("# {{.Title}} ({{.Date}})\nrepo: {{.Repo}}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current dir: %v", err)
+	}
+	defer os.Chdir(originalDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change to temp dir: %v", err)
+	}
+
+	opts := Options{Name: "Sprint 42", Template: "retro", TemplatesDir: templatesDir}
+	path, err := New(".hiden", opts)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if !strings.HasSuffix(path, filepath.Join(".hiden", today, "sprint-42.md")) {
+		t.Errorf("Unexpected path: %s", path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	expected := "# Sprint 42 (" + today + ")\nrepo: " + filepath.Base(tmpDir) + "\n"
+	if string(content) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(content))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat note: %v", err)
+	}
+	if info.Mode().Perm()&0111 != 0 {
+		t.Errorf("Expected non-executable note, got mode %v", info.Mode())
+	}
+
+	// Creating the same note again must not overwrite it
+	if _, err := New(".hiden", opts); err == nil {
+		t.Error("Expected error when the note already exists")
+	}
+
+	// The extension of a title is kept, and left out of {{.Title}}
+	path, err = New(".hiden", Options{Name: "Sprint 43.md", Template: "retro", TemplatesDir: templatesDir})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if filepath.Base(path) != "sprint-43.md" {
+		t.Errorf("Expected sprint-43.md, got %s", filepath.Base(path))
+	}
+	if content, err := os.ReadFile(path); err != nil || !strings.HasPrefix(string(content), "# Sprint 43 (") {
+		t.Errorf("Unexpected content %q (%v)", string(content), err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	// Timestamp-based names created within the same second are numbered
+	for _, expected := range []string{"150405.md", "150405-2.md", "150405-3.md"} {
+		f, path, err := create(dir, "150405.md", true, 0644)
+		if err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+		f.Close()
+		if filepath.Base(path) != expected {
+			t.Errorf("Expected %s, got %s", expected, filepath.Base(path))
+		}
+	}
+
+	// Other names are never reused
+	if _, _, err := create(dir, "150405.md", false, 0644); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected error when the note already exists, got %v", err)
+	}
+}
+
+func TestNew_TemplateNotFound(t *testing.T) {
+	_, err := New(".hiden", Options{Template: "missing", TemplatesDir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected template not found error, got: %v", err)
+	}
+}
+
+func TestNew_ScriptTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := exec.Command("git", "init")
+	cmd.Dir = tmpDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init git repo: %v", err)
+	}
+
+	templatesDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templatesDir, "script.sh"), []byte("#!/bin/sh\n# {{.Name}}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templatesDir, "broken.md"), []byte("{{.Unknown}}"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current dir: %v", err)
+	}
+	defer os.Chdir(originalDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change to temp dir: %v", err)
+	}
+
+	path, err := New(".hiden", Options{Name: "check db", Template: "script", TemplatesDir: templatesDir})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if filepath.Base(path) != "check-db.sh" {
+		t.Errorf("Expected check-db.sh, got %s", filepath.Base(path))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat note: %v", err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("Expected executable note, got mode %v", info.Mode())
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	if string(content) != "#!/bin/sh\n# check-db.sh\n" {
+		t.Errorf("Unexpected content: %q", string(content))
+	}
+
+	if _, err := New(".hiden", Options{Template: "broken", TemplatesDir: templatesDir}); err == nil {
+		t.Error("Expected error for unknown template variable")
+	}
+}
+
+func TestSave(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cmd := exec.Command("git", "init")
+	cmd.Dir = tmpDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init git repo: %v", err)
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current dir: %v", err)
+	}
+	defer os.Chdir(originalDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change to temp dir: %v", err)
+	}
+
+	var tee strings.Builder
+	path, err := Save(".hiden", strings.NewReader("NAME READY\napi 1/1\n"), Options{Name: "pods.txt"}, &tee)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if !strings.HasSuffix(path, filepath.Join(".hiden", today, "pods.txt")) {
+		t.Errorf("Unexpected path: %s", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	if string(content) != "NAME READY\napi 1/1\n" || tee.String() != string(content) {
+		t.Errorf("Unexpected content %q, tee %q", string(content), tee.String())
+	}
+
+	if _, err := Save(".hiden", strings.NewReader("again"), Options{Name: "pods.txt"}, nil); err == nil {
+		t.Error("Expected error when the note already exists")
+	}
+
+	// Unnamed notes saved in the same second get distinct names
+	first, err := Save(".hiden", strings.NewReader("1"), Options{}, nil)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	second, err := Save(".hiden", strings.NewReader("2"), Options{}, nil)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if first == second || filepath.Ext(first) != ".txt" || filepath.Ext(second) != ".txt" {
+		t.Errorf("Expected distinct .txt names, got %s and %s", first, second)
+	}
+}
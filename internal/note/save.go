@@ -0,0 +1,52 @@
+package note
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/qawatake/hiden/internal/mkdir"
+)
+
+// saveExt is the extension of saved files named without one.
+const saveExt = ".txt"
+
+// Save streams r into a new file in the date-based hiden directory of the
+// current git repository, also writing it to tee unless tee is nil.
+// opts.Name is used as in New, with a timestamp-based name when empty;
+// opts.Template is ignored. Returns the absolute path of the file.
+func Save(dirname string, r io.Reader, opts Options, tee io.Writer) (string, error) {
+	now := time.Now()
+
+	name, stamped, err := fileName(opts.Name, saveExt, now)
+	if err != nil {
+		return "", err
+	}
+
+	targetDir, _, err := mkdir.EnsureDir(dirname, opts.Layout, nil)
+	if err != nil {
+		return "", err
+	}
+
+	f, path, err := create(targetDir, name, stamped, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	w := io.Writer(f)
+	if tee != nil {
+		w = io.MultiWriter(f, tee)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,75 @@
+package note
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/qawatake/hiden/internal/repo"
+)
+
+// Vars are the variables available in templates, e.g. {{.Title}}.
+type Vars struct {
+	// Date is the creation date in YYYY-MM-DD format.
+	Date string
+	// Time is the creation time in HH:MM format.
+	Time string
+	// Repo is the name of the repository.
+	Repo string
+	// Branch is the current branch, or empty on a detached HEAD.
+	Branch string
+	// User is the git user.name.
+	User string
+	// Email is the git user.email.
+	Email string
+	// Title is the title given to hiden new, or the note name without extension.
+	Title string
+	// Name is the file name of the note.
+	Name string
+}
+
+// newVars returns the template variables for a note named name in repoRoot.
+func newVars(repoRoot, title, name string, now time.Time) Vars {
+	if title == "" {
+		title = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return Vars{
+		Date:   now.Format("2006-01-02"),
+		Time:   now.Format("15:04"),
+		Repo:   filepath.Base(repoRoot),
+		Branch: repo.Branch(repoRoot),
+		User:   gitOutput(repoRoot, "config", "user.name"),
+		Email:  gitOutput(repoRoot, "config", "user.email"),
+		Title:  title,
+		Name:   name,
+	}
+}
+
+// render expands the variables in a template.
+func render(name string, text []byte, vars Vars) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return nil, fmt.Errorf("failed to expand template: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// gitOutput runs git in dir and returns its trimmed output, or empty on failure.
+func gitOutput(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Display forms accepted by Identity.Format.
+const (
+	DisplayShort = "short"
+	DisplayOwner = "owner"
+	DisplayFull  = "full"
+)
+
+// Displays lists the accepted display forms.
+var Displays = []string{DisplayShort, DisplayOwner, DisplayFull}
+
+// Identity names a repository by its path under a root directory such as
+// the ghq root: host/owner/name, e.g. github.com/org/api. Owner may hold
+// several elements (gitlab.com/group/subgroup/api). Host and Owner are empty
+// when the repository is outside the roots or too shallow under them.
+type Identity struct {
+	Host  string
+	Owner string
+	Name  string
+}
+
+// String returns the full form of the identity, e.g. github.com/org/api.
+func (id Identity) String() string {
+	var parts []string
+	for _, p := range []string{id.Host, id.Owner, id.Name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Format returns the identity in the given display form: DisplayShort (api),
+// DisplayOwner (org/api) or DisplayFull (github.com/org/api).
+// Unknown forms are treated as DisplayShort.
+func (id Identity) Format(display string) string {
+	switch display {
+	case DisplayOwner:
+		if id.Owner != "" {
+			return id.Owner + "/" + id.Name
+		}
+	case DisplayFull:
+		return id.String()
+	}
+	return id.Name
+}
+
+// Match reports whether spec names the repository: it is the name, or a
+// trailing part of the full form such as org/api, compared case-insensitively.
+func (id Identity) Match(spec string) bool {
+	full := strings.ToLower(id.String())
+	spec = strings.ToLower(strings.Trim(spec, "/"))
+	return spec != "" && (full == spec || strings.HasSuffix(full, "/"+spec))
+}
+
+// Identify returns the identity of the repository at path from its path
+// relative to the innermost of roots containing it. Outside the roots,
+// only the name is set, to the base name of path.
+func Identify(path string, roots []string) Identity {
+	var rel string
+	for _, root := range roots {
+		r, err := filepath.Rel(root, path)
+		if err != nil || r == "." || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// Prefer the innermost root, which yields the shortest relative path
+		if rel == "" || len(r) < len(rel) {
+			rel = r
+		}
+	}
+	if rel == "" {
+		return Identity{Name: filepath.Base(path)}
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	n := len(parts)
+	switch n {
+	case 1:
+		return Identity{Name: parts[0]}
+	case 2:
+		return Identity{Owner: parts[0], Name: parts[1]}
+	}
+	return Identity{Host: parts[0], Owner: strings.Join(parts[1:n-1], "/"), Name: parts[n-1]}
+}
@@ -0,0 +1,228 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotInGitRepo = errors.New("not in a git repository")
+
+// Source names accepted by New.
+const (
+	SourceGhq     = "ghq"
+	SourceRoots   = "roots"
+	SourceList    = "list"
+	SourceCurrent = "current"
+)
+
+// Sources lists the accepted source names.
+var Sources = []string{SourceGhq, SourceRoots, SourceList, SourceCurrent}
+
+// Source lists the repositories whose hiden directories are searched.
+type Source interface {
+	// Repos returns the absolute paths of the repositories.
+	Repos() ([]string, error)
+}
+
+// RootLister is implemented by sources whose repositories live under root
+// directories. The path of a repository under its root gives its Identity.
+type RootLister interface {
+	// RootDirs returns the absolute paths of the root directories.
+	RootDirs() ([]string, error)
+}
+
+// New returns the Source named name.
+// roots is used by SourceRoots and repos by SourceList.
+func New(name string, roots, repos []string) (Source, error) {
+	switch name {
+	case SourceGhq, "":
+		return Ghq{}, nil
+	case SourceRoots:
+		if len(roots) == 0 {
+			return nil, errors.New("source \"roots\" requires \"roots\" in config")
+		}
+		return Roots{Dirs: roots}, nil
+	case SourceList:
+		if len(repos) == 0 {
+			return nil, errors.New("source \"list\" requires \"repos\" in config")
+		}
+		return List{Paths: repos}, nil
+	case SourceCurrent:
+		return Current{}, nil
+	}
+	return nil, fmt.Errorf("unknown source %q: must be one of %v", name, Sources)
+}
+
+// Ghq lists the repositories managed by ghq.
+type Ghq struct{}
+
+func (Ghq) Repos() ([]string, error) {
+	cmd := exec.Command("ghq", "list", "--full-path")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'ghq list': %w (is ghq installed?)", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var repos []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			repos = append(repos, line)
+		}
+	}
+
+	if len(repos) == 0 {
+		return nil, errors.New("no repositories found via ghq list")
+	}
+
+	return repos, nil
+}
+
+// RootDirs returns the ghq roots.
+func (Ghq) RootDirs() ([]string, error) {
+	output, err := exec.Command("ghq", "root", "--all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'ghq root': %w", err)
+	}
+	var roots []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			roots = append(roots, line)
+		}
+	}
+	return roots, nil
+}
+
+// Roots lists the git repositories found under the given directories.
+type Roots struct {
+	Dirs []string
+}
+
+func (r Roots) Repos() ([]string, error) {
+	var repos []string
+	for _, dir := range r.Dirs {
+		root, err := ExpandHome(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Skip unreadable directories but fail on a missing root
+				if path == root {
+					return err
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			// Do not descend into hidden directories such as caches
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			// A .git directory, or a .git file for worktrees and submodules
+			if _, err := os.Lstat(filepath.Join(path, ".git")); err == nil {
+				repos = append(repos, path)
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+		}
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no repositories found under %s", strings.Join(r.Dirs, ", "))
+	}
+
+	return repos, nil
+}
+
+// RootDirs returns the directories scanned for repositories.
+func (r Roots) RootDirs() ([]string, error) {
+	dirs := make([]string, 0, len(r.Dirs))
+	for _, dir := range r.Dirs {
+		root, err := ExpandHome(dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, root)
+	}
+	return dirs, nil
+}
+
+// List is an explicit list of repositories.
+type List struct {
+	Paths []string
+}
+
+func (l List) Repos() ([]string, error) {
+	repos := make([]string, 0, len(l.Paths))
+	for _, p := range l.Paths {
+		path, err := ExpandHome(p)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, path)
+	}
+	return repos, nil
+}
+
+// Current is the git repository containing the current directory.
+type Current struct{}
+
+func (Current) Repos() ([]string, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, err
+	}
+	return []string{root}, nil
+}
+
+// Root returns the root directory of the git repository containing the current directory.
+func Root() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		// Check if it's because we're not in a git repo
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 128 {
+			return "", ErrNotInGitRepo
+		}
+		return "", fmt.Errorf("failed to get git repository root: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Branch returns the current branch of the repository at dir,
+// or empty on a detached HEAD or failure.
+func Branch(dir string) string {
+	cmd := exec.Command("git", "branch", "--show-current")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ExpandHome replaces a leading "~" in path with the home directory
+// and makes the path absolute.
+func ExpandHome(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRoots_Repos(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A regular clone, a worktree with a .git file, a nested directory without .git,
+	// and a repository inside a hidden directory which must be skipped
+	dirs := []string{
+		"github.com/org1/repo1/.git",
+		"github.com/org1/repo1/sub/.git",
+		"github.com/org2/repo2",
+		"github.com/org2/empty",
+		".cache/repo3/.git",
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "github.com/org2/repo2/.git"), []byte("gitdir: /elsewhere"), 0644); err != nil {
+		t.Fatalf("Failed to create .git file: %v", err)
+	}
+
+	repos, err := Roots{Dirs: []string{tmpDir}}.Repos()
+	if err != nil {
+		t.Fatalf("Repos failed: %v", err)
+	}
+
+	sort.Strings(repos)
+	expected := []string{
+		filepath.Join(tmpDir, "github.com/org1/repo1"),
+		filepath.Join(tmpDir, "github.com/org2/repo2"),
+	}
+	if !reflect.DeepEqual(repos, expected) {
+		t.Errorf("Expected %v, got %v", expected, repos)
+	}
+}
+
+func TestRoots_NoRepos(t *testing.T) {
+	if _, err := (Roots{Dirs: []string{t.TempDir()}}).Repos(); err == nil {
+		t.Error("Expected error when no repositories are found")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		roots   []string
+		repos   []string
+		want    Source
+		wantErr bool
+	}{
+		{name: "", want: Ghq{}},
+		{name: SourceGhq, want: Ghq{}},
+		{name: SourceRoots, roots: []string{"/src"}, want: Roots{Dirs: []string{"/src"}}},
+		{name: SourceRoots, wantErr: true},
+		{name: SourceList, repos: []string{"/src/a"}, want: List{Paths: []string{"/src/a"}}},
+		{name: SourceList, wantErr: true},
+		{name: SourceCurrent, want: Current{}},
+		{name: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := New(tt.name, tt.roots, tt.repos)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q): expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New(%q): unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("New(%q): expected %#v, got %#v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	roots := []string{"/src", "/src/work"}
+
+	tests := []struct {
+		path     string
+		expected Identity
+	}{
+		{"/src/github.com/org/api", Identity{Host: "github.com", Owner: "org", Name: "api"}},
+		{"/src/gitlab.com/group/sub/api", Identity{Host: "gitlab.com", Owner: "group/sub", Name: "api"}},
+		{"/src/org/api", Identity{Owner: "org", Name: "api"}},
+		// The innermost root is used
+		{"/src/work/tool", Identity{Name: "tool"}},
+		{"/elsewhere/api", Identity{Name: "api"}},
+		{"/srcx/org/api", Identity{Name: "api"}},
+	}
+
+	for _, tt := range tests {
+		if got := Identify(tt.path, roots); got != tt.expected {
+			t.Errorf("Identify(%q): expected %+v, got %+v", tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestIdentity_FormatAndMatch(t *testing.T) {
+	id := Identity{Host: "github.com", Owner: "org1", Name: "api"}
+
+	for display, expected := range map[string]string{
+		DisplayShort: "api",
+		DisplayOwner: "org1/api",
+		DisplayFull:  "github.com/org1/api",
+		"":           "api",
+	} {
+		if got := id.Format(display); got != expected {
+			t.Errorf("Format(%q): expected %q, got %q", display, expected, got)
+		}
+	}
+	if got := (Identity{Name: "api"}).Format(DisplayOwner); got != "api" {
+		t.Errorf("Format(%q) without owner: expected %q, got %q", DisplayOwner, "api", got)
+	}
+
+	for spec, expected := range map[string]bool{
+		"api":                 true,
+		"org1/api":            true,
+		"Org1/API":            true,
+		"github.com/org1/api": true,
+		"org2/api":            false,
+		"pi":                  false,
+		"1/api":               false,
+		"":                    false,
+	} {
+		if got := id.Match(spec); got != expected {
+			t.Errorf("Match(%q): expected %v, got %v", spec, expected, got)
+		}
+	}
+}
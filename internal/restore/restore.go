@@ -0,0 +1,101 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qawatake/hiden/internal/fsutil"
+	"github.com/qawatake/hiden/internal/journal"
+	"github.com/qawatake/hiden/internal/mkdir"
+)
+
+// ErrNoOrigin is returned when no destination is given and the journal has no
+// record of where the file was moved from.
+var ErrNoOrigin = errors.New("no recorded origin")
+
+// ErrExists is returned when the destination already exists.
+var ErrExists = errors.New("destination already exists")
+
+// Options configures Run.
+type Options struct {
+	// Copy copies the file instead of moving it, leaving it in the hiden directory.
+	Copy bool
+	// Journal is used to look up the original location and records the restore
+	// and the created directories.
+	// A nil Journal records nothing.
+	Journal *journal.Journal
+}
+
+// Run moves the file or directory at path in a hiden directory back to dst.
+// An existing directory dst receives the file under its base name.
+// When dst is empty, the file goes back to where hiden mv moved it from.
+// It never overwrites an existing file. Returns the absolute destination path.
+func Run(path, dst string, opts Options) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if dst == "" {
+		dst, err = origin(path, opts.Journal)
+		if err != nil {
+			return "", err
+		}
+	} else if info, err := os.Stat(dst); err == nil && info.IsDir() {
+		dst = filepath.Join(dst, filepath.Base(path))
+	}
+	if dst, err = filepath.Abs(dst); err != nil {
+		return "", err
+	}
+
+	if _, err := os.Lstat(dst); err == nil {
+		return "", fmt.Errorf("failed to restore %s: %w: %s", path, ErrExists, dst)
+	}
+
+	// The original directory may have been removed since the move
+	if err := mkdir.Create(filepath.Dir(dst), opts.Journal); err != nil {
+		return "", err
+	}
+
+	if opts.Copy {
+		err = fsutil.Copy(path, dst)
+	} else {
+		err = fsutil.Move(path, dst)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+
+	if opts.Journal != nil {
+		op := journal.OpRestore
+		if opts.Copy {
+			op = journal.OpCopy
+		}
+		entry, err := journal.NewEntry(op, path, dst)
+		if err == nil {
+			err = opts.Journal.Append(entry)
+		}
+		if err != nil {
+			return dst, fmt.Errorf("failed to record restore: %w", err)
+		}
+	}
+
+	return dst, nil
+}
+
+// origin returns the path the file at path was moved from.
+func origin(path string, j *journal.Journal) (string, error) {
+	if j == nil {
+		return "", fmt.Errorf("%w for %s", ErrNoOrigin, path)
+	}
+	src, ok, err := j.Origin(path)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%w for %s", ErrNoOrigin, path)
+	}
+	return src, nil
+}
@@ -0,0 +1,102 @@
+package restore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qawatake/hiden/internal/journal"
+)
+
+// setup creates a file in a hiden directory and a journal recording that it
+// was moved there from dir/docs/notes.md.
+func setup(t *testing.T) (dir, path, origin string, j *journal.Journal) {
+	t.Helper()
+
+	dir = t.TempDir()
+	path = filepath.Join(dir, ".hiden", "2025-12-04", "notes.md")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create hiden dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	origin = filepath.Join(dir, "docs", "notes.md")
+	j = journal.Open(filepath.Join(t.TempDir(), "journal.jsonl"), "test")
+	if err := j.Append(journal.Entry{Time: time.Now(), Op: journal.OpMove, Src: origin, Dst: path}); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+	return dir, path, origin, j
+}
+
+func TestRun_Origin(t *testing.T) {
+	_, path, origin, j := setup(t)
+
+	dst, err := Run(path, "", Options{Journal: j})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if dst != origin {
+		t.Errorf("Expected %s, got %s", origin, dst)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("File still exists in the hiden directory")
+	}
+	content, err := os.ReadFile(origin)
+	if err != nil || string(content) != "notes" {
+		t.Errorf("Expected restored content %q, got %q (%v)", "notes", string(content), err)
+	}
+
+	entries, err := j.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if last := entries[len(entries)-1]; last.Op != journal.OpRestore || last.Src != path || last.Dst != origin {
+		t.Errorf("Unexpected journal entry: %+v", last)
+	}
+}
+
+func TestRun_CopyToDirectory(t *testing.T) {
+	dir, path, _, j := setup(t)
+
+	dst, err := Run(path, dir, Options{Copy: true, Journal: j})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if expected := filepath.Join(dir, "notes.md"); dst != expected {
+		t.Errorf("Expected %s, got %s", expected, dst)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Copied file was removed from the hiden directory: %v", err)
+	}
+	if _, err := os.Stat(dst); err != nil {
+		t.Errorf("File was not copied: %v", err)
+	}
+}
+
+func TestRun_Refuse(t *testing.T) {
+	_, path, origin, j := setup(t)
+
+	if err := os.MkdirAll(filepath.Dir(origin), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(origin, []byte("existing"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	if _, err := Run(path, "", Options{Journal: j}); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got: %v", err)
+	}
+	content, _ := os.ReadFile(origin)
+	if string(content) != "existing" {
+		t.Errorf("Existing file was overwritten: %q", string(content))
+	}
+
+	other := journal.Open(filepath.Join(t.TempDir(), "journal.jsonl"), "test")
+	if _, err := Run(path, "", Options{Journal: other}); !errors.Is(err, ErrNoOrigin) {
+		t.Errorf("Expected ErrNoOrigin, got: %v", err)
+	}
+}
@@ -1,14 +1,27 @@
 package main
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/qawatake/hiden/internal/config"
 	"github.com/qawatake/hiden/internal/finder"
+	"github.com/qawatake/hiden/internal/index"
+	"github.com/qawatake/hiden/internal/journal"
 	"github.com/qawatake/hiden/internal/mkdir"
 	"github.com/qawatake/hiden/internal/mv"
+	"github.com/qawatake/hiden/internal/note"
+	"github.com/qawatake/hiden/internal/repo"
+	"github.com/qawatake/hiden/internal/restore"
 )
 
 const version = "0.1.0"
@@ -28,6 +41,14 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "grep":
+		if err := runGrep(); err != nil {
+			if errors.Is(err, finder.ErrCancelled) {
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	case "mkdir":
 		if err := runMkdir(); err != nil {
 			if errors.Is(err, mkdir.ErrNotInGitRepo) {
@@ -37,8 +58,38 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
-	case "mv":
-		if err := runMv(); err != nil {
+	case "mv", "cp", "ln":
+		if err := runMv(os.Args[1]); err != nil {
+			if errors.Is(err, mkdir.ErrNotInGitRepo) {
+				fmt.Fprintf(os.Stderr, "error: not in a git repository\n")
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "new":
+		if err := runNew(); err != nil {
+			if errors.Is(err, mkdir.ErrNotInGitRepo) {
+				fmt.Fprintf(os.Stderr, "error: not in a git repository\n")
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "save":
+		if err := runSave(); err != nil {
+			if errors.Is(err, mkdir.ErrNotInGitRepo) {
+				fmt.Fprintf(os.Stderr, "error: not in a git repository\n")
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "restore":
+		if err := runRestore(); err != nil {
+			if errors.Is(err, finder.ErrCancelled) {
+				os.Exit(1)
+			}
 			if errors.Is(err, mkdir.ErrNotInGitRepo) {
 				fmt.Fprintf(os.Stderr, "error: not in a git repository\n")
 				os.Exit(1)
@@ -46,6 +97,21 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "log":
+		if err := runLog(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "undo":
+		if err := runUndo(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "doctor":
+		if err := runDoctor(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	case "version":
 		fmt.Printf("hiden version %s\n", version)
 	case "help", "-h", "--help":
@@ -57,19 +123,217 @@ func main() {
 	}
 }
 
+// searchFlags holds the flags shared by ls and grep.
+type searchFlags struct {
+	line      bool
+	list      bool
+	json      bool
+	null      bool
+	print0    bool
+	query     string
+	selectOne bool
+	exitZero  bool
+	source    string
+	verbose   bool
+}
+
+func newSearchFlags(fs *flag.FlagSet) *searchFlags {
+	f := &searchFlags{}
+	fs.BoolVar(&f.line, "line", false, "append :<line> to the path of a content match")
+	fs.BoolVar(&f.list, "list", false, "print matching files one per line without the selector")
+	fs.BoolVar(&f.json, "json", false, "print matching files as JSON without the selector")
+	fs.BoolVar(&f.null, "null", false, "print matching files separated by NUL without the selector")
+	fs.BoolVar(&f.print0, "print0", false, "separate selected paths with NUL instead of newline")
+	fs.StringVar(&f.query, "query", "", "initial query")
+	fs.BoolVar(&f.selectOne, "select-1", false, "select the only match without the selector")
+	fs.BoolVar(&f.exitZero, "exit-0", false, "exit without the selector when nothing matches")
+	fs.StringVar(&f.source, "source", "", "repository source: ghq, roots, list or current (default from config)")
+	fs.BoolVar(&f.verbose, "verbose", false, "print every file and directory that could not be read")
+	return f
+}
+
 func runLs() error {
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	f := newSearchFlags(fs)
+	fs.Parse(os.Args[2:])
+
+	return find(f, finder.Options{Query: f.query})
+}
+
+func runGrep() error {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	f := newSearchFlags(fs)
+	fs.Parse(os.Args[2:])
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: hiden grep [flags] <pattern>...")
+	}
+
+	return find(f, finder.Options{
+		Query:   strings.TrimSpace(f.query + " " + strings.Join(fs.Args(), " ")),
+		Content: true,
+	})
+}
+
+// printScanReport prints the repositories skipped or slow to scan to stderr.
+// The files and directories that could not be read are only counted unless verbose.
+func printScanReport(report index.Report, verbose bool) {
+	for _, s := range report.Skipped {
+		fmt.Fprintf(os.Stderr, "warning: skipped %s: %v\n", s.Path, s.Err)
+	}
+	for _, s := range report.Slow {
+		fmt.Fprintf(os.Stderr, "warning: slow scan of %s (%s)\n", s.Path, s.Duration.Round(time.Millisecond))
+	}
+	if !verbose {
+		if n := len(report.Errors); n > 0 {
+			fmt.Fprintf(os.Stderr, "warning: %d scan error(s) (use --verbose or hiden doctor for details)\n", n)
+		}
+		return
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", e)
+	}
+}
+
+// scanOptions returns the options shared by every scan of the hiden directories.
+// source overrides the source in cfg unless it is empty.
+func scanOptions(cfg *config.Config, source string) (finder.Options, error) {
+	if source == "" {
+		source = cfg.Source
+	}
+	src, err := repo.New(source, cfg.Roots, cfg.Repos)
+	if err != nil {
+		return finder.Options{}, err
+	}
+
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return finder.Options{}, err
+	}
+
+	return finder.Options{
+		Source:         src,
+		Cache:          filepath.Join(cacheDir, cacheName(cfg, source)),
+		Workers:        cfg.Workers,
+		RepoTimeout:    time.Duration(cfg.RepoTimeout),
+		ScanTimeout:    time.Duration(cfg.ScanTimeout),
+		Ignore:         cfg.Ignore,
+		FollowSymlinks: cfg.FollowSymlinks,
+		RepoDisplay:    cfg.RepoDisplay,
+	}, nil
+}
+
+// cacheName returns the name of the index cache file for source. Keep an
+// index per set of repositories: the name of the source, followed for the
+// sources that depend on the config or the working directory by a hash of
+// what decides their repositories.
+func cacheName(cfg *config.Config, source string) string {
+	var key []string
+	switch source {
+	case repo.SourceGhq, "":
+		return "index-" + repo.SourceGhq + ".json"
+	case repo.SourceRoots:
+		key = cfg.Roots
+	case repo.SourceList:
+		key = cfg.Repos
+	case repo.SourceCurrent:
+		// Outside a repository there is nothing to scan, and so nothing to cache
+		root, err := repo.Root()
+		if err != nil {
+			return "index-" + source + ".json"
+		}
+		key = []string{root}
+	}
+
+	expanded := make([]string, 0, len(key))
+	for _, k := range key {
+		if path, err := repo.ExpandHome(k); err == nil {
+			k = path
+		}
+		expanded = append(expanded, k)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(expanded, "\x00")))
+	return fmt.Sprintf("index-%s-%x.json", source, sum[:8])
+}
+
+func find(f *searchFlags, opts finder.Options) error {
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	path, err := finder.Run(cfg.Dirname)
+	scan, err := scanOptions(cfg, f.source)
 	if err != nil {
 		return err
 	}
 
-	if path != "" {
-		fmt.Println(path)
+	opts.Source = scan.Source
+	opts.Cache = scan.Cache
+	opts.Workers = scan.Workers
+	opts.RepoTimeout = scan.RepoTimeout
+	opts.ScanTimeout = scan.ScanTimeout
+	opts.Ignore = scan.Ignore
+	opts.FollowSymlinks = scan.FollowSymlinks
+	opts.RepoDisplay = scan.RepoDisplay
+	opts.Ranking = finder.Ranking(cfg.Ranking)
+	opts.Line = f.line
+	opts.SelectOne = f.selectOne
+	opts.ExitZero = f.exitZero
+	opts.Report = func(report index.Report) {
+		printScanReport(report, f.verbose)
+	}
+
+	if f.list || f.json || f.null {
+		files, err := finder.List(cfg.Dirname, opts)
+		if err != nil {
+			return err
+		}
+		return printFiles(files, f)
+	}
+
+	paths, err := finder.Run(cfg.Dirname, opts)
+	if err != nil {
+		return err
+	}
+
+	sep := "\n"
+	if f.print0 {
+		sep = "\x00"
+	}
+	for _, path := range paths {
+		fmt.Print(path + sep)
+	}
+	return nil
+}
+
+// printFiles prints files in the output mode selected by f.
+func printFiles(files []finder.File, f *searchFlags) error {
+	if f.json {
+		if files == nil {
+			files = []finder.File{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(files)
+	}
+
+	sep := "\n"
+	if f.null {
+		sep = "\x00"
+	}
+
+	seen := make(map[string]bool)
+	for _, file := range files {
+		path := file.Path
+		if f.line && file.Line > 0 {
+			path = fmt.Sprintf("%s:%d", file.Path, file.Line)
+		}
+		// Content matches yield one file per line; print each path once
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		fmt.Print(path + sep)
 	}
 	return nil
 }
@@ -80,7 +344,12 @@ func runMkdir() error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	dirPath, err := mkdir.Run(cfg.Dirname)
+	j, err := openJournal("mkdir")
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := mkdir.Run(cfg.Dirname, cfg.Layout, j)
 	if err != nil {
 		return err
 	}
@@ -89,35 +358,399 @@ func runMkdir() error {
 	return nil
 }
 
-func runMv() error {
-	if len(os.Args) < 3 {
-		return fmt.Errorf("usage: hiden mv <file>...")
+// actions maps the commands run by runMv to what they do with each file.
+var actions = map[string]mv.Action{
+	"mv": mv.ActionMove,
+	"cp": mv.ActionCopy,
+	"ln": mv.ActionLink,
+}
+
+func runMv(command string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	force := fs.Bool("force", false, "overwrite existing files")
+	noClobber := fs.Bool("no-clobber", false, "skip files whose destination exists")
+	backup := fs.Bool("backup", false, "rename existing files to numbered backups (name.~N~)")
+	interactive := fs.Bool("interactive", false, "prompt before overwriting existing files")
+	dryRun := fs.Bool("dry-run", false, "print the planned moves without moving anything")
+	atomic := fs.Bool("atomic", false, "move all files or none, moving files back if a move fails")
+	var parents bool
+	fs.BoolVar(&parents, "parents", false, "keep the path of each file relative to the repository root")
+	fs.BoolVar(&parents, "keep-path", false, "same as --parents")
+	fs.Parse(os.Args[2:])
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: hiden %s [flags] <file>...", command)
+	}
+
+	conflict, err := conflictPolicy(*force, *noClobber, *backup, *interactive)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	j, err := openJournal(command)
+	if err != nil {
+		return err
+	}
+
+	opts := mv.Options{
+		Layout:   cfg.Layout,
+		Action:   actions[command],
+		Conflict: conflict,
+		Atomic:   *atomic,
+		Parents:  parents,
+		Journal:  j,
+		Confirm: func(dst string) bool {
+			return confirm(fmt.Sprintf("overwrite %s?", dst))
+		},
+	}
+
+	filePaths := fs.Args()
+	if *dryRun {
+		moves, err := mv.Plan(cfg.Dirname, filePaths, opts)
+		if err != nil {
+			return err
+		}
+		for _, m := range moves {
+			switch {
+			case m.Skip:
+				fmt.Printf("%s -> %s (skipped: exists)\n", m.Src, m.Rel)
+			case m.Backup != "":
+				fmt.Printf("%s -> %s (backup: %s)\n", m.Src, m.Rel, filepath.Base(m.Backup))
+			case m.Exists && conflict == mv.ConflictPrompt:
+				fmt.Printf("%s -> %s (exists: prompt)\n", m.Src, m.Rel)
+			case m.Exists:
+				fmt.Printf("%s -> %s (overwrite)\n", m.Src, m.Rel)
+			default:
+				fmt.Printf("%s -> %s\n", m.Src, m.Rel)
+			}
+		}
+		return nil
+	}
+
+	_, err = mv.Run(cfg.Dirname, filePaths, opts)
+	if err != nil {
+		if errors.Is(err, mv.ErrExists) {
+			return fmt.Errorf("%w (use --force, --backup, --no-clobber or --interactive)", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// conflictPolicy returns the conflict policy selected by the mutually exclusive flags.
+func conflictPolicy(force, noClobber, backup, interactive bool) (mv.Conflict, error) {
+	conflict := mv.ConflictRefuse
+	n := 0
+	for _, f := range []struct {
+		set      bool
+		conflict mv.Conflict
+	}{
+		{force, mv.ConflictOverwrite},
+		{noClobber, mv.ConflictSkip},
+		{backup, mv.ConflictBackup},
+		{interactive, mv.ConflictPrompt},
+	} {
+		if f.set {
+			conflict = f.conflict
+			n++
+		}
+	}
+	if n > 1 {
+		return 0, errors.New("--force, --no-clobber, --backup and --interactive are mutually exclusive")
+	}
+	return conflict, nil
+}
+
+// confirm asks a yes/no question on the terminal and reports whether the answer is yes.
+func confirm(question string) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func runSave() error {
+	fs := flag.NewFlagSet("save", flag.ExitOnError)
+	tee := fs.Bool("tee", false, "also write stdin to stdout, printing the path to stderr")
+	fs.Parse(os.Args[2:])
+
+	// Refuse to wait for typed input
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return fmt.Errorf("no input: pipe the content to save, e.g. 'pbpaste | hiden save'")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var w io.Writer
+	if *tee {
+		w = os.Stdout
+	}
+	path, err := note.Save(cfg.Dirname, os.Stdin, note.Options{
+		Name:   strings.Join(fs.Args(), " "),
+		Layout: cfg.Layout,
+	}, w)
+	if err != nil {
+		return err
+	}
+
+	if *tee {
+		fmt.Fprintln(os.Stderr, path)
+	} else {
+		fmt.Println(path)
+	}
+	return nil
+}
+
+func runRestore() error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	cp := fs.Bool("copy", false, "copy the file, leaving it in the hiden directory")
+	query := fs.String("query", "", "start the selector with the query")
+	fs.Parse(os.Args[2:])
+
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: hiden restore [flags] [dest]")
+	}
+	dst := fs.Arg(0)
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	j, err := openJournal("restore")
+	if err != nil {
+		return err
+	}
+
+	paths, err := finder.Run(cfg.Dirname, finder.Options{
+		Query:          *query,
+		Ranking:        finder.Ranking(cfg.Ranking),
+		Source:         repo.Current{},
+		Ignore:         cfg.Ignore,
+		FollowSymlinks: cfg.FollowSymlinks,
+		RepoDisplay:    cfg.RepoDisplay,
+		// Keep the mtime, which the restored file carries back and undo checks
+		NoTouch: true,
+	})
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no files in the hiden directory")
+	}
+
+	if len(paths) > 1 && dst != "" {
+		if info, err := os.Stat(dst); err != nil || !info.IsDir() {
+			return fmt.Errorf("destination must be a directory when restoring multiple files: %s", dst)
+		}
+	}
+
+	for _, path := range paths {
+		restored, err := restore.Run(path, dst, restore.Options{Copy: *cp, Journal: j})
+		if err != nil {
+			if errors.Is(err, restore.ErrNoOrigin) {
+				return fmt.Errorf("%w (specify a destination)", err)
+			}
+			return err
+		}
+		fmt.Println(restored)
+	}
+	return nil
+}
+
+// openJournal returns the journal of file operations in the state directory,
+// recording a new batch made by command.
+func openJournal(command string) (*journal.Journal, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return journal.Open(filepath.Join(dir, "journal.jsonl"), command), nil
+}
+
+func runLog() error {
+	fs := flag.NewFlagSet("log", flag.ExitOnError)
+	limit := fs.Int("n", 0, "show only the latest n entries")
+	jsonOut := fs.Bool("json", false, "output entries as JSON lines")
+	fs.Parse(os.Args[2:])
+
+	j, err := openJournal("log")
+	if err != nil {
+		return err
+	}
+
+	entries, err := j.Entries()
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[len(entries)-*limit:]
+	}
+
+	// Show the latest entries first
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if *jsonOut {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			continue
+		}
+
+		target := e.Dst
+		switch {
+		case e.Op == journal.OpUndo && e.Src == "":
+			target = "(batch " + e.Undone + ": nothing changed)"
+		case e.Op == journal.OpUndo && e.Dst == "":
+			target = "remove " + e.Src
+		case e.Src != "":
+			target = e.Src + " -> " + e.Dst
+		}
+		fmt.Printf("%s  %s  %-8s %-8s %s\n", e.Batch, e.Time.Local().Format("2006-01-02 15:04:05"), e.Command, e.Op, target)
+	}
+	return nil
+}
+
+func runUndo() error {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+
+	j, err := openJournal("undo")
+	if err != nil {
+		return err
+	}
+
+	batch, err := j.Undo()
+	if err != nil {
+		if errors.Is(err, journal.ErrChanged) {
+			return fmt.Errorf("%w (refusing to undo)", err)
+		}
+		return err
 	}
 
+	for i := len(batch) - 1; i >= 0; i-- {
+		e := batch[i]
+		switch e.Op {
+		case journal.OpMkdir:
+		case journal.OpCopy, journal.OpLink:
+			fmt.Printf("removed %s\n", e.Dst)
+		default:
+			fmt.Printf("%s -> %s\n", e.Dst, e.Src)
+		}
+	}
+	return nil
+}
+
+func runDoctor() error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	source := fs.String("source", "", "repository source: ghq, roots, list or current (default from config)")
+	fs.Parse(os.Args[2:])
+
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	filePaths := os.Args[2:]
-	_, err = mv.Run(cfg.Dirname, filePaths)
+	opts, err := scanOptions(cfg, *source)
+	if err != nil {
+		return err
+	}
+
+	sum, err := finder.Check(cfg.Dirname, opts)
 	if err != nil {
 		return err
 	}
 
+	fmt.Printf("%d repositories, %d with a %s directory, %d files\n", sum.Repos, sum.HidenDirs, cfg.Dirname, sum.Files)
+	for _, s := range sum.Skipped {
+		fmt.Printf("skipped %s: %v\n", s.Path, s.Err)
+	}
+	for _, s := range sum.Slow {
+		fmt.Printf("slow scan of %s (%s)\n", s.Path, s.Duration.Round(time.Millisecond))
+	}
+	for _, e := range sum.Errors {
+		fmt.Println(e)
+	}
+
+	if n := sum.Warnings(); n > 0 {
+		return fmt.Errorf("found %d problem(s)", n)
+	}
+	fmt.Println("no problems found")
+	return nil
+}
+
+func runNew() error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	tmpl := fs.String("template", "", "name of a template in ~/.config/hiden/templates")
+	noEdit := fs.Bool("no-edit", false, "do not open the note in $VISUAL or $EDITOR")
+	fs.Parse(os.Args[2:])
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	templatesDir, err := config.TemplatesDir()
+	if err != nil {
+		return err
+	}
+
+	path, err := note.New(cfg.Dirname, note.Options{
+		Name:         strings.Join(fs.Args(), " "),
+		Template:     *tmpl,
+		TemplatesDir: templatesDir,
+		Layout:       cfg.Layout,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !*noEdit {
+		if err := note.Edit(path); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(path)
 	return nil
 }
 
 func printHelp() {
-	fmt.Println(`hiden - Search personal memo/script directories across ghq repositories
+	fmt.Println(`hiden - Search personal memo/script directories across repositories
 
 Usage:
   hiden <command>
 
 Commands:
-  ls           Search and select files from hiden directories
-  mkdir        Create a date-based directory in the hiden directory
-  mv <file>...  Move files to the date-based hiden directory
-  version      Print version information
-  help         Print this help message`)
+  ls                Search and select files from hiden directories
+  grep <pattern>    Search file contents in hiden directories
+  mkdir             Create a date-based directory in the hiden directory
+  mv <file>...      Move files to the date-based hiden directory
+  cp <file>...      Copy files to the date-based hiden directory
+  ln <file>...      Create symlinks to files in the date-based hiden directory
+  new [name]        Create a note in the date-based hiden directory and open it
+  save [name]       Save stdin to a file in the date-based hiden directory
+  restore [dest]    Move a file from the hiden directory back to where it came from
+  log               Show the journal of moves and created directories
+  undo              Revert the last mkdir, mv, cp, ln or restore
+  doctor            Scan every hiden directory and report what could not be read
+  version           Print version information
+  help              Print this help message`)
 }